@@ -2,18 +2,22 @@ package api
 
 import (
 	"database/sql"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/lib/pq"
 
 	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/metrics"
+	"github.com/keremakillioglu/simplebank/token"
 
 	"github.com/gin-gonic/gin"
 )
 
 // balance is zero initially
+// owner is not part of the request, it is taken from the access token
 type createAccountRequest struct {
-	Owner    string `json:"owner" binding:"required"`
 	Currency string `json:"currency" binding:"required,currency"`
 }
 
@@ -28,36 +32,109 @@ func (server *Server) createAccount(ctx *gin.Context) {
 		return
 	}
 
+	// user can only create an account for himself/herself
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	idem, err := newIdempotencyParams(ctx, authPayload.Username, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
 	//if no errors
 	arg := db.CreateAccountParams{
-		Owner:    req.Owner,
+		Owner:    authPayload.Username,
 		Balance:  0,
 		Currency: req.Currency,
 	}
 
+	// retried request gets the account created by the first one
+	if idem != nil {
+		result, err := server.store.IdempotentCreateAccountTx(ctx.Request.Context(), *idem, arg)
+		if err != nil {
+			handleCreateAccountError(ctx, err)
+			return
+		}
+		if !result.Replayed {
+			metrics.AccountCreated(arg.Currency)
+		}
+
+		writeIdempotentResponse(ctx, result)
+		return
+	}
+
 	// return created account in db & error
-	account, err := server.store.CreateAccount(ctx, arg)
+	account, err := server.store.CreateAccount(ctx.Request.Context(), arg)
 	if err != nil {
+		handleCreateAccountError(ctx, err)
+		return
+	}
+	metrics.AccountCreated(account.Currency)
+
+	// successfully created the account
+	ctx.JSON(http.StatusOK, account)
 
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "foreign_key_violation", "unique_violationn":
-				// status forbidden (code 403), error message
-				ctx.JSON(http.StatusForbidden, errorResponse(err))
-				return
+}
 
-			}
+// handleCreateAccountError writes the response for an error returned while creating an account
+func handleCreateAccountError(ctx *gin.Context, err error) {
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code.Name() {
+		case "foreign_key_violation", "unique_violationn":
+			// status forbidden (code 403), error message
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
 
 		}
 
-		// internal error (code 500), error message
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+
+	if errors.Is(err, db.ErrIdempotencyKeyReused) {
+		ctx.JSON(http.StatusConflict, errorResponse(err))
 		return
 	}
 
-	// successfully created the account
-	ctx.JSON(http.StatusOK, account)
+	// internal error (code 500), error message
+	ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+}
+
+// accountResponse reports the balance of the account together with the part of it that can be spent
+// funds reserved by pending holds are not available, see AuthorizeTx
+type accountResponse struct {
+	db.Account
+	AvailableBalance int64 `json:"available_balance"`
+}
+
+func newAccountResponse(account db.Account, held int64) accountResponse {
+	return accountResponse{
+		Account:          account,
+		AvailableBalance: account.Balance - held,
+	}
+}
 
+// newAccountResponses gets the held amounts of all accounts with a single query
+func (server *Server) newAccountResponses(ctx *gin.Context, accounts []db.Account) ([]accountResponse, error) {
+	ids := make([]int64, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+
+	rows, err := server.store.ListHeldAmounts(ctx.Request.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// accounts without pending holds have no rows
+	held := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		held[row.AccountID] = row.HeldAmount
+	}
+
+	rsp := make([]accountResponse, len(accounts))
+	for i, account := range accounts {
+		rsp[i] = newAccountResponse(account, held[account.ID])
+	}
+	return rsp, nil
 }
 
 type getAccountRequest struct {
@@ -74,7 +151,7 @@ func (server *Server) getAccount(ctx *gin.Context) {
 		return
 	}
 
-	account, err := server.store.GetAccount(ctx, req.ID)
+	account, err := server.store.GetAccount(ctx.Request.Context(), req.ID)
 	if err != nil {
 
 		if err == sql.ErrNoRows {
@@ -87,13 +164,119 @@ func (server *Server) getAccount(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, account)
+	// user can only get the accounts that he/she owns
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if account.Owner != authPayload.Username {
+		err := errors.New("account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	held, err := server.store.GetHeldAmount(ctx.Request.Context(), account.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAccountResponse(account, held))
+
+}
+
+type changeAccountStatusRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// reason is optional, the body can be empty
+type changeAccountStatusBody struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// freezeAccount blocks all transfers from and to the account until it is unfrozen
+// owners freeze their own accounts, and admins can freeze any account
+func (server *Server) freezeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountStatusFrozen)
+}
+
+// unfreezeAccount is allowed to the user who froze the account or an admin
+func (server *Server) unfreezeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountStatusActive)
+}
+
+// closeAccount closes an account with zero balance, closed accounts can still be queried but never used again
+func (server *Server) closeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountStatusClosed)
+}
+
+func (server *Server) changeAccountStatus(ctx *gin.Context, status string) {
+	var req changeAccountStatusRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body changeAccountStatusBody
+	if err := ctx.ShouldBindJSON(&body); err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	account, err := server.existingAccount(ctx, req.ID)
+	if err != nil {
+		return
+	}
+
+	// the role is read from the db like in adminMiddleware
+	user, err := server.store.GetUser(ctx.Request.Context(), authPayload.Username)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	isAdmin := err == nil && user.Role == db.UserRoleAdmin
+
+	// user can only change the status of his/her own accounts, admins of any account
+	if account.Owner != authPayload.Username && !isAdmin {
+		err := errors.New("account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	account, err = server.store.ChangeAccountStatusTx(ctx.Request.Context(), db.ChangeAccountStatusTxParams{
+		AccountID: req.ID,
+		Status:    status,
+		Reason:    body.Reason,
+		ChangedBy: authPayload.Username,
+		ByAdmin:   isAdmin,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrInvalidStatusTransition):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		case errors.Is(err, db.ErrFrozenByAnotherUser):
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+		case errors.Is(err, db.ErrBalanceNotZero):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
 
+	ctx.JSON(http.StatusOK, account)
 }
 
+// cursor is the next_cursor of the previous page, the first page is returned without it
+// page_id is deprecated, it uses OFFSET pagination and returns a plain list without a cursor
 type listAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+	PageID   int32  `form:"page_id" binding:"omitempty,min=1"`
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Cursor   string `form:"cursor"`
+}
+
+type listAccountResponse struct {
+	Accounts   []accountResponse `json:"accounts"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
 }
 
 func (server *Server) listAccount(ctx *gin.Context) {
@@ -104,25 +287,82 @@ func (server *Server) listAccount(ctx *gin.Context) {
 		return
 	}
 
+	// only the accounts of the authenticated user are listed
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if req.PageID > 0 {
+		server.listAccountByPage(ctx, req, authPayload.Username)
+		return
+	}
+
+	list := "accounts:" + authPayload.Username
+	arg := db.ListAccountsAfterParams{
+		Owner: authPayload.Username,
+		Limit: pageLimit(req.PageSize),
+	}
+
+	if len(req.Cursor) > 0 {
+		cursor, err := server.decodeCursor(list, req.Cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		arg.AfterCreatedAt = cursor.CreatedAt
+		arg.AfterID = cursor.ID
+	}
+
+	accounts, err := server.store.ListAccountsAfter(ctx.Request.Context(), arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var rsp listAccountResponse
+	if len(accounts) == int(arg.Limit) {
+		// the extra row only tells that there is a next page
+		accounts = accounts[:len(accounts)-1]
+		last := accounts[len(accounts)-1]
+		rsp.NextCursor = server.encodeCursor(list, last.ID, last.CreatedAt)
+		rsp.HasMore = true
+	}
+
+	rsp.Accounts, err = server.newAccountResponses(ctx, accounts)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// listAccountByPage serves the deprecated page_id/page_size parameters with OFFSET pagination
+func (server *Server) listAccountByPage(ctx *gin.Context, req listAccountRequest, owner string) {
+	if req.PageSize < 5 || req.PageSize > 10 {
+		err := errors.New("page_size must be between 5 and 10 when page_id is used")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
 	// limit= pagesize, offset= number of records that db should skip
 	arg := db.ListAccountsParams{
+		Owner:  owner,
 		Limit:  req.PageSize,
 		Offset: (req.PageID - 1) * req.PageSize,
 	}
 
-	account, err := server.store.ListAccounts(ctx, arg)
+	accounts, err := server.store.ListAccounts(ctx.Request.Context(), arg)
 	if err != nil {
-
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-
 		// internal error (code 500), error message
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, account)
+	rsp, err := server.newAccountResponses(ctx, accounts)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 
+	ctx.Header("Deprecation", "true")
+	ctx.JSON(http.StatusOK, rsp)
 }
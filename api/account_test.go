@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -16,19 +17,23 @@ import (
 
 	"github.com/golang/mock/gomock"
 
+	"github.com/keremakillioglu/simplebank/token"
 	"github.com/keremakillioglu/simplebank/util"
 
 	db "github.com/keremakillioglu/simplebank/db/sqlc"
 )
 
 func TestGetAccountAPI(t *testing.T) {
-	account := randomAccount()
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
 
 	// for table- driven test set to cover all posible scenarios of the GetAccount API
 	// store test cases in an anonymous struct
 	testCases := []struct {
 		name      string
 		accountID int64
+		// add authorization header to the request
+		setupAuth func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 		// build stubs
 		buildStubs func(store *mockdb.MockStore)
 		//check the output of the idea
@@ -36,6 +41,9 @@ func TestGetAccountAPI(t *testing.T) {
 	}{{
 		name:      "OK",
 		accountID: account.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+		},
 		buildStubs: func(store *mockdb.MockStore) {
 			// build stubs
 			// gomock expects get account function to be called with any context and have an id equal to accountid created above
@@ -43,6 +51,10 @@ func TestGetAccountAPI(t *testing.T) {
 				GetAccount(gomock.Any(), gomock.Eq(account.ID)).
 				Times(1).
 				Return(account, nil)
+			store.EXPECT().
+				GetHeldAmount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).
+				Return(int64(10), nil)
 		},
 		checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 			//check response
@@ -50,9 +62,38 @@ func TestGetAccountAPI(t *testing.T) {
 			//check body
 			requireBodyMatchAccount(t, recorder.Body, account)
 		},
+	}, {
+		name:      "AVAILABLEBALANCE",
+		accountID: account.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+		},
+		buildStubs: func(store *mockdb.MockStore) {
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).
+				Return(account, nil)
+			// pending holds reduce the available balance, but not the balance
+			store.EXPECT().
+				GetHeldAmount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).
+				Return(int64(10), nil)
+		},
+		checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var rsp accountResponse
+			err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+			require.NoError(t, err)
+			require.Equal(t, account.Balance, rsp.Balance)
+			require.Equal(t, account.Balance-10, rsp.AvailableBalance)
+		},
 	}, {
 		name:      "NOTFOUND",
 		accountID: account.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+		},
 		buildStubs: func(store *mockdb.MockStore) {
 			// build stubs
 			// gomock expects get account function to be called with any context and have an id equal to accountid created above
@@ -70,6 +111,9 @@ func TestGetAccountAPI(t *testing.T) {
 		{
 			name:      "INTERNALERROR",
 			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
 			buildStubs: func(store *mockdb.MockStore) {
 				// build stubs
 				// gomock expects get account function to be called with any context and have an id equal to accountid created above
@@ -85,9 +129,46 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 		},
 
+		{
+			name:      "UNAUTHORIZEDUSER",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				// token belongs to someone else than the owner of the account
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+
+		{
+			name:      "NOAUTHORIZATION",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				// request is rejected by the middleware before reaching the handler
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+
 		{
 			name:      "INVALIDID",
 			accountID: 0,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
 			buildStubs: func(store *mockdb.MockStore) {
 				// build stubs
 				// gomock expects get account function to be called with any context and have an id equal to accountid created above
@@ -117,7 +198,7 @@ func TestGetAccountAPI(t *testing.T) {
 			tc.buildStubs(store)
 
 			//start test server and send a request
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			// url path of the api we want to call
@@ -126,6 +207,7 @@ func TestGetAccountAPI(t *testing.T) {
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
 
+			tc.setupAuth(t, request, server.tokenMaker)
 			// send the request via server.router and record response at recorder
 			server.router.ServeHTTP(recorder, request)
 
@@ -137,12 +219,13 @@ func TestGetAccountAPI(t *testing.T) {
 
 }
 
-func randomAccount() db.Account {
+func randomAccount(owner string) db.Account {
 	return db.Account{
 		ID:       util.RandomInt(1, 1000),
-		Owner:    util.RandomOwner(),
+		Owner:    owner,
 		Balance:  util.RandomMoney(),
 		Currency: util.RandomCurrency(),
+		Status:   db.AccountStatusActive,
 	}
 
 }
@@ -159,3 +242,336 @@ func requireBodyMatchAccount(t *testing.T, body *bytes.Buffer, account db.Accoun
 	require.NoError(t, err)
 	require.Equal(t, account, accountFromResponse)
 }
+
+func TestListAccountsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	n := 3
+	accounts := make([]db.Account, n)
+	for i := 0; i < n; i++ {
+		accounts[i] = randomAccount(user.Username)
+		accounts[i].ID = int64(i + 1)
+		accounts[i].CreatedAt = time.Now().UTC().Truncate(time.Second).Add(time.Duration(i) * time.Minute)
+	}
+
+	list := "accounts:" + user.Username
+
+	testCases := []struct {
+		name          string
+		query         func(server *Server) string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "FIRSTPAGE",
+			query: func(server *Server) string { return "page_size=2" },
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsAfterParams{
+					Owner: user.Username,
+					Limit: 3,
+				}
+				store.EXPECT().ListAccountsAfter(gomock.Any(), gomock.Eq(arg)).Times(1).Return(accounts, nil)
+
+				// the extra account is not part of the page
+				held := []db.ListHeldAmountsRow{{AccountID: accounts[0].ID, HeldAmount: 10}}
+				store.EXPECT().ListHeldAmounts(gomock.Any(), gomock.Eq([]int64{accounts[0].ID, accounts[1].ID})).Times(1).Return(held, nil)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp listAccountResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.True(t, rsp.HasMore)
+				require.Len(t, rsp.Accounts, 2)
+				require.Equal(t, accounts[0].Balance-10, rsp.Accounts[0].AvailableBalance)
+				require.Equal(t, accounts[1].Balance, rsp.Accounts[1].AvailableBalance)
+
+				cursor, err := server.decodeCursor(list, rsp.NextCursor)
+				require.NoError(t, err)
+				require.Equal(t, accounts[1].ID, cursor.ID)
+			},
+		},
+		{
+			name: "LASTPAGE",
+			query: func(server *Server) string {
+				return "page_size=2&cursor=" + server.encodeCursor(list, accounts[1].ID, accounts[1].CreatedAt)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsAfter(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ListAccountsAfterParams) ([]db.Account, error) {
+						require.Equal(t, accounts[1].ID, arg.AfterID)
+						require.True(t, accounts[1].CreatedAt.Equal(arg.AfterCreatedAt))
+						return accounts[2:], nil
+					})
+				store.EXPECT().ListHeldAmounts(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp listAccountResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.False(t, rsp.HasMore)
+				require.Empty(t, rsp.NextCursor)
+				require.Len(t, rsp.Accounts, 1)
+			},
+		},
+		{
+			name:  "CURSOROFANOTHERUSER",
+			query: func(server *Server) string { return "cursor=" + server.encodeCursor("accounts:someone", 1, time.Now()) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsAfter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "DEPRECATEDPAGEID",
+			query: func(server *Server) string { return "page_id=2&page_size=5" },
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsParams{
+					Owner:  user.Username,
+					Limit:  5,
+					Offset: 5,
+				}
+				store.EXPECT().ListAccounts(gomock.Any(), gomock.Eq(arg)).Times(1).Return(accounts, nil)
+				store.EXPECT().ListHeldAmounts(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "true", recorder.Header().Get("Deprecation"))
+
+				// the old response is a plain list
+				var rsp []db.Account
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp, n)
+			},
+		},
+		{
+			name:  "DEPRECATEDPAGESIZETOOLARGE",
+			query: func(server *Server) string { return "page_id=1&page_size=20" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "INTERNALERROR",
+			query: func(server *Server) string { return "" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsAfter(gomock.Any(), gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, server *Server, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/accounts?" + tc.query(server)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, server, recorder)
+		})
+	}
+}
+
+func TestChangeAccountStatusAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	admin, _ := randomUser(t)
+	admin.Role = db.UserRoleAdmin
+
+	frozen := account
+	frozen.Status = db.AccountStatusFrozen
+	frozen.StatusReason = "lost card"
+	frozen.StatusChangedBy = user.Username
+
+	testCases := []struct {
+		name          string
+		action        string
+		body          string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "FREEZE",
+			action:   "freeze",
+			body:     `{"reason": "lost card"}`,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ChangeAccountStatusTxParams{
+					AccountID: account.ID,
+					Status:    db.AccountStatusFrozen,
+					Reason:    "lost card",
+					ChangedBy: user.Username,
+				}
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(frozen, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, frozen)
+			},
+		},
+		{
+			name:     "UNFREEZEWITHOUTBODY",
+			action:   "unfreeze",
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ChangeAccountStatusTxParams{
+					AccountID: account.ID,
+					Status:    db.AccountStatusActive,
+					ChangedBy: user.Username,
+				}
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(frozen, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name:     "CLOSENONZEROBALANCE",
+			action:   "close",
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.Account{}, fmt.Errorf("account [%d]: %w", account.ID, db.ErrBalanceNotZero))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name:     "INVALIDTRANSITION",
+			action:   "unfreeze",
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.Account{}, db.ErrInvalidStatusTransition)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "UNAUTHORIZEDUSER",
+			action:   "close",
+			username: "unauthorized_user",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq("unauthorized_user")).Times(1).Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "ADMINFREEZE",
+			action:   "freeze",
+			body:     `{"reason": "fraud investigation"}`,
+			username: admin.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ChangeAccountStatusTxParams{
+					AccountID: account.ID,
+					Status:    db.AccountStatusFrozen,
+					Reason:    "fraud investigation",
+					ChangedBy: admin.Username,
+					ByAdmin:   true,
+				}
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(frozen, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "UNFREEZEFROZENBYADMIN",
+			action:   "unfreeze",
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				// the owner cannot undo the freeze of an admin, the store checks who froze the account
+				arg := db.ChangeAccountStatusTxParams{
+					AccountID: account.ID,
+					Status:    db.AccountStatusActive,
+					ChangedBy: user.Username,
+				}
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(frozen, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.Account{}, fmt.Errorf("account [%d]: %w", account.ID, db.ErrFrozenByAnotherUser))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "INVALIDBODY",
+			action:   "freeze",
+			body:     `{"reason": 1}`,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/%s", account.ID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
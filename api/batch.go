@@ -0,0 +1,137 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/metrics"
+	"github.com/keremakillioglu/simplebank/token"
+)
+
+// modes of a batch transfer
+const (
+	batchModeAllOrNothing = "all_or_nothing"
+	batchModeBestEffort   = "best_effort"
+)
+
+// all legs are paid from the same account, in its currency
+// number of legs is limited to keep a single batch within a reasonable tx duration
+type batchTransferRequest struct {
+	FromAccountID int64                   `json:"from_account_id" binding:"required,min=1"`
+	Currency      string                  `json:"currency" binding:"required,currency"`
+	Mode          string                  `json:"mode" binding:"required,oneof=all_or_nothing best_effort"`
+	Legs          []batchTransferLegInput `json:"legs" binding:"required,min=1,max=1000,dive"`
+}
+
+type batchTransferLegInput struct {
+	ToAccountID int64 `json:"to_account_id" binding:"required,min=1"`
+	Amount      int64 `json:"amount" binding:"required,gt=0"`
+}
+
+// statuses of the legs in the response
+const (
+	legStatusSucceeded = "succeeded"
+	legStatusFailed    = "failed"
+)
+
+type batchTransferLegResponse struct {
+	ToAccountID int64                `json:"to_account_id"`
+	Amount      int64                `json:"amount"`
+	Status      string               `json:"status"`
+	Transfer    *db.TransferTxResult `json:"transfer,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+type batchTransferResponse struct {
+	Batch     db.TransferBatch           `json:"batch"`
+	Legs      []batchTransferLegResponse `json:"legs"`
+	Succeeded int                        `json:"succeeded"`
+	Failed    int                        `json:"failed"`
+}
+
+// createBatchTransfer pays many accounts from one account of the user in a single db transaction
+func (server *Server) createBatchTransfer(ctx *gin.Context) {
+	var req batchTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	for i, leg := range req.Legs {
+		if leg.ToAccountID == req.FromAccountID {
+			err := fmt.Errorf("leg [%d]: cannot transfer to the from account", i)
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	fromAccount, err := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if err != nil {
+		metrics.TransferFailed(transferFailureReason(err))
+		return
+	}
+
+	// user can only send money from his/her own account
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if fromAccount.Owner != authPayload.Username {
+		err := errors.New("from account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	arg := db.BatchTransferTxParams{
+		FromAccountID: req.FromAccountID,
+		Legs:          make([]db.BatchTransferLeg, len(req.Legs)),
+		BestEffort:    req.Mode == batchModeBestEffort,
+	}
+	for i, leg := range req.Legs {
+		arg.Legs[i] = db.BatchTransferLeg{
+			ToAccountID: leg.ToAccountID,
+			Amount:      leg.Amount,
+		}
+	}
+
+	result, err := server.store.BatchTransferTx(ctx.Request.Context(), arg)
+	if err != nil {
+		// a to account of an all or nothing batch doesn't exist
+		if errors.Is(err, sql.ErrNoRows) {
+			metrics.TransferFailed(metrics.ReasonNotFound)
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		handleTransferError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newBatchTransferResponse(req, result))
+}
+
+func newBatchTransferResponse(req batchTransferRequest, result db.BatchTransferTxResult) batchTransferResponse {
+	rsp := batchTransferResponse{
+		Batch: result.Batch,
+		Legs:  make([]batchTransferLegResponse, len(result.Legs)),
+	}
+
+	for i, leg := range result.Legs {
+		rsp.Legs[i] = batchTransferLegResponse{
+			ToAccountID: req.Legs[i].ToAccountID,
+			Amount:      req.Legs[i].Amount,
+			Status:      legStatusSucceeded,
+			Transfer:    leg.Transfer,
+		}
+
+		if leg.Err != nil {
+			rsp.Legs[i].Status = legStatusFailed
+			rsp.Legs[i].Error = leg.Err.Error()
+			rsp.Failed++
+			continue
+		}
+		rsp.Succeeded++
+	}
+
+	return rsp
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTransferAPI(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account3 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+
+	legs := []gin.H{
+		{"to_account_id": account2.ID, "amount": 10},
+		{"to_account_id": account3.ID, "amount": 20},
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "ALLORNOTHING",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"currency":        util.USD,
+				"mode":            batchModeAllOrNothing,
+				"legs":            legs,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.BatchTransferTxParams{
+					FromAccountID: account1.ID,
+					Legs: []db.BatchTransferLeg{
+						{ToAccountID: account2.ID, Amount: 10},
+						{ToAccountID: account3.ID, Amount: 20},
+					},
+				}
+
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().
+					BatchTransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.BatchTransferTxResult{
+						Batch: db.TransferBatch{ID: 1, FromAccountID: account1.ID},
+						Legs:  []db.BatchTransferLegResult{{Transfer: &db.TransferTxResult{}}, {Transfer: &db.TransferTxResult{}}},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp batchTransferResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Equal(t, int64(1), rsp.Batch.ID)
+				require.Equal(t, 2, rsp.Succeeded)
+				require.Zero(t, rsp.Failed)
+			},
+		},
+		{
+			name: "BESTEFFORT",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"currency":        util.USD,
+				"mode":            batchModeBestEffort,
+				"legs":            legs,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().
+					BatchTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.BatchTransferTxParams) (db.BatchTransferTxResult, error) {
+						require.True(t, arg.BestEffort)
+						return db.BatchTransferTxResult{
+							Legs: []db.BatchTransferLegResult{
+								{Transfer: &db.TransferTxResult{}},
+								{Err: db.ErrInsufficientFunds},
+							},
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp batchTransferResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Equal(t, 1, rsp.Succeeded)
+				require.Equal(t, 1, rsp.Failed)
+				require.Equal(t, legStatusSucceeded, rsp.Legs[0].Status)
+				require.Equal(t, legStatusFailed, rsp.Legs[1].Status)
+				require.Equal(t, account3.ID, rsp.Legs[1].ToAccountID)
+				require.Equal(t, db.ErrInsufficientFunds.Error(), rsp.Legs[1].Error)
+			},
+		},
+		{
+			name: "ALLORNOTHINGFAILED",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"currency":        util.USD,
+				"mode":            batchModeAllOrNothing,
+				"legs":            legs,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().
+					BatchTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.BatchTransferTxResult{}, fmt.Errorf("leg [1]: %w", db.ErrInsufficientFunds))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name: "UNAUTHORIZEDUSER",
+			body: gin.H{
+				"from_account_id": account2.ID,
+				"currency":        account2.Currency,
+				"mode":            batchModeAllOrNothing,
+				"legs":            []gin.H{{"to_account_id": account1.ID, "amount": 10}},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "TOFROMACCOUNT",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"currency":        util.USD,
+				"mode":            batchModeAllOrNothing,
+				"legs":            []gin.H{{"to_account_id": account1.ID, "amount": 10}},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "INVALIDLEG",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"currency":        util.USD,
+				"mode":            batchModeAllOrNothing,
+				"legs":            []gin.H{{"to_account_id": account2.ID, "amount": -10}},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NOLEGS",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"currency":        util.USD,
+				"mode":            batchModeAllOrNothing,
+				"legs":            []gin.H{},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers/batch", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestBatchRouteNextToTransferID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the static batch segment and the :id wildcard are registered for POST side by side
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(int64(12))).Times(1).Return(db.Transfer{}, sql.ErrNoRows)
+
+	server := newTestServer(t, store)
+
+	testCases := []struct {
+		url  string
+		code int
+	}{
+		// an empty batch is rejected by the batch handler
+		{url: "/transfers/batch", code: http.StatusBadRequest},
+		// the reversal still gets the id
+		{url: "/transfers/12/reverse", code: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, tc.url, bytes.NewReader([]byte(`{}`)))
+		require.NoError(t, err)
+
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, util.RandomOwner(), time.Minute)
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, tc.code, recorder.Code, tc.url)
+	}
+}
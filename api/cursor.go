@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// page sizes of the cursor paginated lists
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// errInvalidCursor is returned for a cursor that was not created by the server, or was created for another list
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor is the position of the last item of a page
+// lists are ordered by (created_at, id), so the position stays valid when new rows are inserted
+type pageCursor struct {
+	// list the cursor was created for, e.g. "transfers:42"
+	List      string    `json:"l"`
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"ts"`
+}
+
+// newCursorKey derives the key that signs the cursors from the token key
+// so a leaked cursor key cannot be used to forge tokens
+func newCursorKey(tokenSymmetricKey string) []byte {
+	mac := hmac.New(sha256.New, []byte(tokenSymmetricKey))
+	mac.Write([]byte("simplebank pagination cursor"))
+	return mac.Sum(nil)
+}
+
+// encodeCursor returns an opaque cursor pointing after the item with the given id and created_at
+// the cursor is base64(json) + "." + base64(hmac), clients cannot change it without the server noticing
+func (server *Server) encodeCursor(list string, id int64, createdAt time.Time) string {
+	payload, err := json.Marshal(pageCursor{List: list, ID: id, CreatedAt: createdAt})
+	if err != nil {
+		// marshalling a struct of a string, an int and a time cannot fail
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(server.signCursor(payload))
+}
+
+// decodeCursor checks the signature of the cursor and that it belongs to the list
+func (server *Server) decodeCursor(list string, cursor string) (pageCursor, error) {
+	parts := bytes.Split([]byte(cursor), []byte("."))
+	if len(parts) != 2 {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	if !hmac.Equal(signature, server.signCursor(payload)) {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	if c.List != list {
+		return pageCursor{}, fmt.Errorf("%w: cursor belongs to another list", errInvalidCursor)
+	}
+
+	return c, nil
+}
+
+func (server *Server) signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, server.cursorKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// pageLimit is the number of rows to query for a page of pageSize items
+// one more row is queried to know whether there is a next page
+func pageLimit(pageSize int32) int32 {
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	return pageSize + 1
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	server := newTestServer(t, nil)
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	cursor := server.encodeCursor("accounts:alice", 42, createdAt)
+
+	c, err := server.decodeCursor("accounts:alice", cursor)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), c.ID)
+	require.True(t, createdAt.Equal(c.CreatedAt))
+
+	// cursor of another list
+	_, err = server.decodeCursor("accounts:bob", cursor)
+	require.True(t, errors.Is(err, errInvalidCursor))
+
+	// signed by another server
+	otherServer := newTestServer(t, nil)
+	_, err = otherServer.decodeCursor("accounts:alice", cursor)
+	require.True(t, errors.Is(err, errInvalidCursor))
+
+	// payload of another cursor with the signature of this one
+	other := server.encodeCursor("accounts:alice", 43, createdAt)
+	tampered := strings.Split(other, ".")[0] + "." + strings.Split(cursor, ".")[1]
+	_, err = server.decodeCursor("accounts:alice", tampered)
+	require.True(t, errors.Is(err, errInvalidCursor))
+
+	for _, invalid := range []string{"", "abc", "a.b.c", "!!!.???"} {
+		_, err = server.decodeCursor("accounts:alice", invalid)
+		require.True(t, errors.Is(err, errInvalidCursor), invalid)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/token"
+)
+
+type listEntriesRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// cursor is the next_cursor of the previous page, the first page is returned without it
+type listEntriesQuery struct {
+	Cursor   string `form:"cursor"`
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type entryResponse struct {
+	ID        int64 `json:"id"`
+	AccountID int64 `json:"account_id"`
+	Amount    int64 `json:"amount"`
+	// omitted for entries not created by a transfer
+	TransferID int64     `json:"transfer_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newEntryResponse(entry db.Entry) entryResponse {
+	return entryResponse{
+		ID:         entry.ID,
+		AccountID:  entry.AccountID,
+		Amount:     entry.Amount,
+		TransferID: entry.TransferID.Int64,
+		CreatedAt:  entry.CreatedAt,
+	}
+}
+
+type listEntriesResponse struct {
+	Entries    []entryResponse `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	HasMore    bool            `json:"has_more"`
+}
+
+// listEntries lists the entries of an account of the user, oldest first
+func (server *Server) listEntries(ctx *gin.Context) {
+	var req listEntriesRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var query listEntriesQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	list := fmt.Sprintf("entries:%d", req.ID)
+	arg := db.ListEntriesAfterParams{
+		AccountID: req.ID,
+		Limit:     pageLimit(query.PageSize),
+	}
+
+	if len(query.Cursor) > 0 {
+		cursor, err := server.decodeCursor(list, query.Cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		arg.AfterCreatedAt = cursor.CreatedAt
+		arg.AfterID = cursor.ID
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, valid := server.ownedAccount(ctx, req.ID, authPayload.Username); !valid {
+		return
+	}
+
+	entries, err := server.store.ListEntriesAfter(ctx.Request.Context(), arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// the extra row only tells that there is a next page
+	hasMore := len(entries) == int(arg.Limit)
+	if hasMore {
+		entries = entries[:len(entries)-1]
+	}
+
+	rsp := listEntriesResponse{
+		Entries: make([]entryResponse, 0, len(entries)),
+		HasMore: hasMore,
+	}
+	for _, entry := range entries {
+		rsp.Entries = append(rsp.Entries, newEntryResponse(entry))
+	}
+	if hasMore {
+		last := entries[len(entries)-1]
+		rsp.NextCursor = server.encodeCursor(list, last.ID, last.CreatedAt)
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type previewFeeRequest struct {
+	Currency string `form:"currency" binding:"required,currency"`
+	Amount   int64  `form:"amount" binding:"required,gt=0"`
+}
+
+type previewFeeResponse struct {
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+	Fee      int64  `json:"fee"`
+	// Total is debited from the from account, amount plus fee
+	Total int64 `json:"total"`
+}
+
+// previewFee returns the fee of a transfer before it is submitted
+// the currency is the one of the from account, the fee is charged in it
+func (server *Server) previewFee(ctx *gin.Context) {
+	var req previewFeeRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	quote, err := server.feeSchedule.Quote(req.Currency, req.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, previewFeeResponse{
+		Currency: quote.Currency,
+		Amount:   quote.Amount,
+		Fee:      quote.Fee,
+		Total:    quote.Amount + quote.Fee,
+	})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+)
+
+// statuses of the health checks
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// healthz is the liveness probe, it only shows the process is serving requests
+// it doesnt check the dependencies, so a db outage doesnt get the server restarted
+func (server *Server) healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, healthResponse{Status: healthOK})
+}
+
+type dependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newDependencyStatus(err error) dependencyStatus {
+	if err != nil {
+		return dependencyStatus{Status: healthUnavailable, Error: err.Error()}
+	}
+	return dependencyStatus{Status: healthOK}
+}
+
+type readinessResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyStatus `json:"dependencies"`
+}
+
+// readyz is the readiness probe, it fails while the server cannot serve requests or is shutting down
+// every dependency is checked and reported, even after one of them fails
+func (server *Server) readyz(ctx *gin.Context) {
+	checkCtx := ctx.Request.Context()
+	if server.config.ReadinessTimeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(checkCtx, server.config.ReadinessTimeout)
+		defer cancel()
+	}
+
+	var serverErr error
+	if atomic.LoadInt32(&server.shuttingDown) != 0 {
+		serverErr = errShuttingDown
+	}
+
+	rsp := readinessResponse{
+		Status: healthOK,
+		Dependencies: map[string]dependencyStatus{
+			"server":     newDependencyStatus(serverErr),
+			"database":   newDependencyStatus(server.store.Ping(checkCtx)),
+			"migrations": newDependencyStatus(server.checkSchemaVersion(checkCtx)),
+		},
+	}
+
+	for _, dependency := range rsp.Dependencies {
+		if dependency.Status != healthOK {
+			rsp.Status = healthUnavailable
+			ctx.JSON(http.StatusServiceUnavailable, rsp)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// checkSchemaVersion checks that the db is migrated to the version the binary is written for
+func (server *Server) checkSchemaVersion(ctx context.Context) error {
+	version, err := server.store.GetSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version.Dirty {
+		return fmt.Errorf("migration %d failed and is dirty", version.Version)
+	}
+	if version.Version != db.ExpectedSchemaVersion {
+		return fmt.Errorf("schema version is %d, expected %d", version.Version, db.ExpectedSchemaVersion)
+	}
+	return nil
+}
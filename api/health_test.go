@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessAPI(t *testing.T) {
+	testCases := []struct {
+		name          string
+		shuttingDown  bool
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().GetSchemaVersion(gomock.Any()).Times(1).Return(db.SchemaVersion{Version: db.ExpectedSchemaVersion}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				rsp := readinessBody(t, recorder)
+				require.Equal(t, healthOK, rsp.Status)
+				require.Len(t, rsp.Dependencies, 3)
+			},
+		},
+		{
+			// every dependency is reported, not only the first one that fails
+			name: "DatabaseDown",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(errors.New("connection refused"))
+				store.EXPECT().GetSchemaVersion(gomock.Any()).Times(1).Return(db.SchemaVersion{}, errors.New("connection refused"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				rsp := readinessBody(t, recorder)
+				require.Equal(t, healthUnavailable, rsp.Status)
+				require.Equal(t, healthOK, rsp.Dependencies["server"].Status)
+				require.Equal(t, dependencyStatus{Status: healthUnavailable, Error: "connection refused"}, rsp.Dependencies["database"])
+			},
+		},
+		{
+			name: "OldSchema",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().GetSchemaVersion(gomock.Any()).Times(1).Return(db.SchemaVersion{Version: db.ExpectedSchemaVersion - 1}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				rsp := readinessBody(t, recorder)
+				require.Equal(t, healthOK, rsp.Dependencies["database"].Status)
+				require.Equal(t, healthUnavailable, rsp.Dependencies["migrations"].Status)
+			},
+		},
+		{
+			name: "DirtySchema",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().GetSchemaVersion(gomock.Any()).Times(1).Return(db.SchemaVersion{Version: db.ExpectedSchemaVersion, Dirty: true}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Equal(t, healthUnavailable, readinessBody(t, recorder).Dependencies["migrations"].Status)
+			},
+		},
+		{
+			name:         "ShuttingDown",
+			shuttingDown: true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().GetSchemaVersion(gomock.Any()).Times(1).Return(db.SchemaVersion{Version: db.ExpectedSchemaVersion}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Equal(t, healthUnavailable, readinessBody(t, recorder).Dependencies["server"].Status)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			if tc.shuttingDown {
+				atomic.StoreInt32(&server.shuttingDown, 1)
+			}
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func readinessBody(t *testing.T, recorder *httptest.ResponseRecorder) readinessResponse {
+	var rsp readinessResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	return rsp
+}
+
+func TestLivenessAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// liveness doesnt touch the db
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	atomic.StoreInt32(&server.shuttingDown, 1)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
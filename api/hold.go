@@ -0,0 +1,210 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/token"
+)
+
+// holds reserve funds of the from account, they are captured into a transfer later on
+// both accounts must have the same currency, the rate of a cross currency transfer cannot be locked until the capture
+type createHoldRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	Currency      string `json:"currency" binding:"required,currency"`
+}
+
+// holdResponse reports an expired hold as expired, although its status column is still pending
+type holdResponse struct {
+	db.Hold
+	Status string `json:"status"`
+}
+
+func newHoldResponse(hold db.Hold) holdResponse {
+	return holdResponse{
+		Hold:   hold,
+		Status: hold.EffectiveStatus(time.Now()),
+	}
+}
+
+// createHold authorizes an amount on an account of the user, it expires after the configured hold duration
+func (server *Server) createHold(ctx *gin.Context) {
+	var req createHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	fromAccount, err := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if err != nil {
+		return
+	}
+
+	// user can only reserve money of his/her own account
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if fromAccount.Owner != authPayload.Username {
+		err := errors.New("from account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if _, err := server.validAccount(ctx, req.ToAccountID, req.Currency); err != nil {
+		return
+	}
+
+	hold, err := server.store.AuthorizeTx(ctx.Request.Context(), db.AuthorizeTxParams{
+		AccountID:   req.FromAccountID,
+		ToAccountID: req.ToAccountID,
+		Amount:      req.Amount,
+		ExpiresAt:   time.Now().Add(server.config.HoldDuration),
+	})
+	if err != nil {
+		handleHoldError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+type holdRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getHold returns a hold if the user owns one of its accounts
+func (server *Server) getHold(ctx *gin.Context) {
+	var req holdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	hold, valid := server.authorizedHold(ctx, req.ID, authPayload.Username, false)
+	if !valid {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+// amount is optional, the rest of a partially captured hold is released
+type captureHoldBody struct {
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+type captureHoldResponse struct {
+	Hold     holdResponse        `json:"hold"`
+	Transfer db.TransferTxResult `json:"transfer"`
+}
+
+// captureHold transfers the held amount to the to account
+// only the owner of the to account can capture, like a merchant settling a card payment
+func (server *Server) captureHold(ctx *gin.Context) {
+	var req holdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body captureHoldBody
+	if err := ctx.ShouldBindJSON(&body); err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, valid := server.authorizedHold(ctx, req.ID, authPayload.Username, true); !valid {
+		return
+	}
+
+	result, err := server.store.CaptureTx(ctx.Request.Context(), db.CaptureTxParams{
+		HoldID: req.ID,
+		Amount: body.Amount,
+	})
+	if err != nil {
+		handleHoldError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, captureHoldResponse{
+		Hold:     newHoldResponse(result.Hold),
+		Transfer: result.Transfer,
+	})
+}
+
+// voidHold releases the held amount, owners of both accounts can void a hold
+func (server *Server) voidHold(ctx *gin.Context) {
+	var req holdRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, valid := server.authorizedHold(ctx, req.ID, authPayload.Username, false); !valid {
+		return
+	}
+
+	hold, err := server.store.VoidTx(ctx.Request.Context(), req.ID)
+	if err != nil {
+		handleHoldError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+// authorizedHold gets the hold, and writes the error response if it cannot be found or the user owns none of its accounts
+// only the owner of the to account is authorized when toOnly is set
+func (server *Server) authorizedHold(ctx *gin.Context, holdID int64, username string, toOnly bool) (db.Hold, bool) {
+	hold, err := server.store.GetHold(ctx.Request.Context(), holdID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return hold, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return hold, false
+	}
+
+	accountIDs := []int64{hold.AccountID, hold.ToAccountID}
+	if toOnly {
+		accountIDs = accountIDs[1:]
+	}
+
+	for _, accountID := range accountIDs {
+		account, err := server.existingAccount(ctx, accountID)
+		if err != nil {
+			return hold, false
+		}
+		if account.Owner == username {
+			return hold, true
+		}
+	}
+
+	err = fmt.Errorf("hold [%d] doesn't belong to the authenticated user", hold.ID)
+	ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	return hold, false
+}
+
+// handleHoldError writes the response for an error returned by the hold transactions
+func handleHoldError(ctx *gin.Context, err error) {
+	switch {
+	// hold was captured, voided or has expired in the meantime
+	case errors.Is(err, db.ErrHoldNotPending), errors.Is(err, db.ErrHoldExpired):
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+	case errors.Is(err, db.ErrCaptureExceedsHold):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+	default:
+		// a capture fails like a transfer
+		handleTransferError(ctx, err)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+)
+
+const (
+	// clients send this header to make a POST request safe to retry
+	idempotencyKeyHeader = "Idempotency-Key"
+	// set on responses that are replayed from an earlier request with the same key
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// newIdempotencyParams returns nil params when the client didnt send an idempotency key
+// the fingerprint is computed from the parsed request, so formatting differences of the body dont matter
+func newIdempotencyParams(ctx *gin.Context, username string, req interface{}) (*db.IdempotencyParams, error) {
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if len(key) == 0 {
+		return nil, nil
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+
+	return &db.IdempotencyParams{
+		Username:       username,
+		IdempotencyKey: key,
+		RequestPath:    ctx.FullPath(),
+		RequestHash:    hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// replayIdempotentRequest writes the stored response if the request with this key was already completed
+// it returns true if a response has been written and the handler should stop
+func (server *Server) replayIdempotentRequest(ctx *gin.Context, idem db.IdempotencyParams) bool {
+	key, err := server.store.GetIdempotencyKey(ctx.Request.Context(), db.GetIdempotencyKeyParams{
+		Username:       idem.Username,
+		IdempotencyKey: idem.IdempotencyKey,
+	})
+	if err != nil {
+		// key is seen for the first time
+		if err == sql.ErrNoRows {
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return true
+	}
+
+	if key.RequestPath != idem.RequestPath || key.RequestHash != idem.RequestHash {
+		ctx.JSON(http.StatusConflict, errorResponse(db.ErrIdempotencyKeyReused))
+		return true
+	}
+
+	// request is still in progress, the idempotent tx will wait for it to finish
+	if key.ResponseBody == nil {
+		return false
+	}
+
+	writeIdempotentResponse(ctx, db.IdempotentTxResult{
+		Response: key.ResponseBody,
+		Replayed: true,
+	})
+	return true
+}
+
+// writeIdempotentResponse sends the stored JSON response of an idempotent request as it is
+func writeIdempotentResponse(ctx *gin.Context, result db.IdempotentTxResult) {
+	if result.Replayed {
+		ctx.Header(idempotentReplayedHeader, "true")
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", result.Response)
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+)
+
+// limits set on a level, a missing or null limit is inherited from the level above: account, user, default
+// amounts are in the minor units of the account currency, user limits apply to each account of the user
+type transferLimitsBody struct {
+	DailyAmount          *int64 `json:"daily_amount" binding:"omitempty,gt=0"`
+	MonthlyAmount        *int64 `json:"monthly_amount" binding:"omitempty,gt=0"`
+	PerTransactionAmount *int64 `json:"per_transaction_amount" binding:"omitempty,gt=0"`
+	HourlyCount          *int32 `json:"hourly_count" binding:"omitempty,gt=0"`
+}
+
+func newTransferLimitsBody(limit db.TransferLimit) transferLimitsBody {
+	var body transferLimitsBody
+	if limit.DailyAmount.Valid {
+		body.DailyAmount = &limit.DailyAmount.Int64
+	}
+	if limit.MonthlyAmount.Valid {
+		body.MonthlyAmount = &limit.MonthlyAmount.Int64
+	}
+	if limit.PerTransactionAmount.Valid {
+		body.PerTransactionAmount = &limit.PerTransactionAmount.Int64
+	}
+	if limit.HourlyCount.Valid {
+		body.HourlyCount = &limit.HourlyCount.Int32
+	}
+	return body
+}
+
+// nullInt64 returns a null value for a missing limit
+func nullInt64(value *int64) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *value, Valid: true}
+}
+
+func nullInt32(value *int32) sql.NullInt32 {
+	if value == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *value, Valid: true}
+}
+
+type transferLimitsResponse struct {
+	// Limits are the ones set on this level
+	Limits transferLimitsBody `json:"limits"`
+	// Effective are the limits after inheritance, zero means no limit
+	Effective db.TransferLimits `json:"effective"`
+}
+
+// getDefaultLimits returns the system defaults, they apply when neither the account nor its owner has a limit
+func (server *Server) getDefaultLimits(ctx *gin.Context) {
+	limit, err := server.store.GetDefaultTransferLimit(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transferLimitsResponse{
+		Limits:    newTransferLimitsBody(limit),
+		Effective: db.EffectiveTransferLimits([]db.TransferLimit{limit}),
+	})
+}
+
+// updateDefaultLimits replaces the system defaults, a missing limit means no limit
+func (server *Server) updateDefaultLimits(ctx *gin.Context) {
+	var body transferLimitsBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	limit, err := server.store.UpdateDefaultTransferLimit(ctx.Request.Context(), db.UpdateDefaultTransferLimitParams{
+		DailyAmount:          nullInt64(body.DailyAmount),
+		MonthlyAmount:        nullInt64(body.MonthlyAmount),
+		PerTransactionAmount: nullInt64(body.PerTransactionAmount),
+		HourlyCount:          nullInt32(body.HourlyCount),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transferLimitsResponse{
+		Limits:    newTransferLimitsBody(limit),
+		Effective: db.EffectiveTransferLimits([]db.TransferLimit{limit}),
+	})
+}
+
+type accountLimitsRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getAccountLimits returns the limits of any account, including the ones inherited from its owner
+func (server *Server) getAccountLimits(ctx *gin.Context) {
+	var req accountLimitsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.existingAccount(ctx, req.ID)
+	if err != nil {
+		return
+	}
+
+	server.writeAccountLimits(ctx, account)
+}
+
+// updateAccountLimits replaces the limits of an account, a missing limit is inherited
+func (server *Server) updateAccountLimits(ctx *gin.Context) {
+	var req accountLimitsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body transferLimitsBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.existingAccount(ctx, req.ID)
+	if err != nil {
+		return
+	}
+
+	_, err = server.store.UpsertAccountTransferLimit(ctx.Request.Context(), db.UpsertAccountTransferLimitParams{
+		AccountID:            sql.NullInt64{Int64: account.ID, Valid: true},
+		DailyAmount:          nullInt64(body.DailyAmount),
+		MonthlyAmount:        nullInt64(body.MonthlyAmount),
+		PerTransactionAmount: nullInt64(body.PerTransactionAmount),
+		HourlyCount:          nullInt32(body.HourlyCount),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.writeAccountLimits(ctx, account)
+}
+
+// writeAccountLimits writes the limits of the account, with the same rows TransferTx uses
+func (server *Server) writeAccountLimits(ctx *gin.Context, account db.Account) {
+	rows, err := server.store.ListApplicableTransferLimits(ctx.Request.Context(), db.ListApplicableTransferLimitsParams{
+		AccountID: sql.NullInt64{Int64: account.ID, Valid: true},
+		Owner:     sql.NullString{String: account.Owner, Valid: true},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := transferLimitsResponse{
+		Effective: db.EffectiveTransferLimits(rows),
+	}
+	for _, row := range rows {
+		if row.AccountID.Valid {
+			rsp.Limits = newTransferLimitsBody(row)
+		}
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type userLimitsRequest struct {
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+// getUserLimits returns the limits of a user, they apply to the accounts of the user without their own limits
+func (server *Server) getUserLimits(ctx *gin.Context) {
+	var req userLimitsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, valid := server.existingUser(ctx, req.Username); !valid {
+		return
+	}
+
+	username := sql.NullString{String: req.Username, Valid: true}
+	limit, err := server.store.GetUserTransferLimit(ctx.Request.Context(), username)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		// a user without limits of its own inherits all of the defaults
+		limit = db.TransferLimit{Username: username}
+	}
+
+	server.writeUserLimits(ctx, limit)
+}
+
+// updateUserLimits replaces the limits of a user, a missing limit is inherited from the defaults
+func (server *Server) updateUserLimits(ctx *gin.Context) {
+	var req userLimitsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body transferLimitsBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, valid := server.existingUser(ctx, req.Username); !valid {
+		return
+	}
+
+	limit, err := server.store.UpsertUserTransferLimit(ctx.Request.Context(), db.UpsertUserTransferLimitParams{
+		Username:             sql.NullString{String: req.Username, Valid: true},
+		DailyAmount:          nullInt64(body.DailyAmount),
+		MonthlyAmount:        nullInt64(body.MonthlyAmount),
+		PerTransactionAmount: nullInt64(body.PerTransactionAmount),
+		HourlyCount:          nullInt32(body.HourlyCount),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.writeUserLimits(ctx, limit)
+}
+
+// writeUserLimits writes the limits of the user, merged with the system defaults
+func (server *Server) writeUserLimits(ctx *gin.Context, limit db.TransferLimit) {
+	defaults, err := server.store.GetDefaultTransferLimit(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transferLimitsResponse{
+		Limits:    newTransferLimitsBody(limit),
+		Effective: db.EffectiveTransferLimits([]db.TransferLimit{defaults, limit}),
+	})
+}
+
+// existingUser gets the user, and writes the error response if it cannot be found
+func (server *Server) existingUser(ctx *gin.Context, username string) (db.User, bool) {
+	user, err := server.store.GetUser(ctx.Request.Context(), username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return user, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return user, false
+	}
+	return user, true
+}
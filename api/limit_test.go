@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateAccountLimitsAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = db.UserRoleAdmin
+	customer, _ := randomUser(t)
+	customer.Role = db.UserRoleCustomer
+
+	account := randomAccount(customer.Username)
+
+	defaults := db.TransferLimit{
+		ID:          1,
+		DailyAmount: sql.NullInt64{Int64: 10000, Valid: true},
+		HourlyCount: sql.NullInt32{Int32: 10, Valid: true},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: admin.Username,
+			body:     gin.H{"daily_amount": 500, "per_transaction_amount": 100},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+
+				limit := db.TransferLimit{
+					ID:                   2,
+					AccountID:            sql.NullInt64{Int64: account.ID, Valid: true},
+					DailyAmount:          sql.NullInt64{Int64: 500, Valid: true},
+					PerTransactionAmount: sql.NullInt64{Int64: 100, Valid: true},
+				}
+				store.EXPECT().
+					UpsertAccountTransferLimit(gomock.Any(), gomock.Eq(db.UpsertAccountTransferLimitParams{
+						AccountID:            limit.AccountID,
+						DailyAmount:          limit.DailyAmount,
+						PerTransactionAmount: limit.PerTransactionAmount,
+					})).
+					Times(1).
+					Return(limit, nil)
+				store.EXPECT().
+					ListApplicableTransferLimits(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.TransferLimit{defaults, limit}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp transferLimitsResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+
+				// monthly and hourly limits are not set on the account
+				require.Equal(t, int64(500), *rsp.Limits.DailyAmount)
+				require.Nil(t, rsp.Limits.HourlyCount)
+
+				require.Equal(t, db.TransferLimits{
+					DailyAmount:          500,
+					PerTransactionAmount: 100,
+					HourlyCount:          10,
+				}, rsp.Effective)
+			},
+		},
+		{
+			name:     "NOTADMIN",
+			username: customer.Username,
+			body:     gin.H{"daily_amount": 500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(customer.Username)).Times(1).Return(customer, nil)
+				store.EXPECT().UpsertAccountTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "ACCOUNTNOTFOUND",
+			username: admin.Username,
+			body:     gin.H{"daily_amount": 500},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().UpsertAccountTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "NEGATIVELIMIT",
+			username: admin.Username,
+			body:     gin.H{"daily_amount": -1},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				store.EXPECT().UpsertAccountTransferLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/limits/accounts/%d", account.ID)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetUserLimitsAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = db.UserRoleAdmin
+	customer, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	defaults := db.TransferLimit{
+		ID:            1,
+		DailyAmount:   sql.NullInt64{Int64: 10000, Valid: true},
+		MonthlyAmount: sql.NullInt64{Int64: 100000, Valid: true},
+	}
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(customer.Username)).Times(1).Return(customer, nil)
+	store.EXPECT().GetUserTransferLimit(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferLimit{}, sql.ErrNoRows)
+	store.EXPECT().GetDefaultTransferLimit(gomock.Any()).Times(1).Return(defaults, nil)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/admin/limits/users/%s", customer.Username)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, admin.Username, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// a user without limits inherits the defaults
+	var rsp transferLimitsResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	require.Equal(t, transferLimitsBody{}, rsp.Limits)
+	require.Equal(t, db.TransferLimits{DailyAmount: 10000, MonthlyAmount: 100000}, rsp.Effective)
+}
+
+func TestTransferLimitExceededAPI(t *testing.T) {
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+	account1.Balance = 1000
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+	store.EXPECT().
+		TransferTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.TransferTxResult{}, &db.LimitError{AccountID: account1.ID, Limit: db.LimitDaily, Max: 50, Actual: 110})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(gin.H{
+		"from_account_id": account1.ID,
+		"to_account_id":   account2.ID,
+		"amount":          60,
+		"currency":        util.USD,
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+
+	// the limit hit is named in the response
+	var rsp gin.H
+	err = json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	require.Equal(t, db.LimitDaily, rsp["limit"])
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/keremakillioglu/simplebank/logging"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDHeaderKey = "X-Request-ID"
+	// longer request ids from the client are replaced, so they cannot flood the logs
+	maxRequestIDLength = 128
+	// only the beginning of an error response is kept for the log
+	maxLoggedErrorSize = 1024
+)
+
+// loggingMiddleware logs every request with its method, path, status, latency, user and error
+// the request id of the client is kept if it is valid and a new one is generated otherwise,
+// it is sent back in the X-Request-ID header and passed to the store in the context
+func loggingMiddleware(logger zerolog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		requestID := ctx.GetHeader(requestIDHeaderKey)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		// handlers pass the request context to the store, so the store logs the id too
+		ctx.Request = ctx.Request.WithContext(logging.WithRequestID(ctx.Request.Context(), requestID))
+		ctx.Header(requestIDHeaderKey, requestID)
+
+		recorder := &errorRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+
+		var event *zerolog.Event
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = logger.Error()
+		case status >= http.StatusBadRequest:
+			event = logger.Warn()
+		default:
+			event = logger.Info()
+		}
+
+		event = event.
+			Str(logging.RequestIDField, requestID).
+			Str("method", ctx.Request.Method).
+			Str("path", ctx.Request.URL.Path).
+			Int("status", status).
+			Dur("latency", time.Since(start))
+
+		if spanContext := trace.SpanFromContext(ctx.Request.Context()).SpanContext(); spanContext.IsValid() {
+			event = event.Str("trace_id", spanContext.TraceID().String())
+		}
+
+		// set by authMiddleware, the public routes have no user
+		if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+			event = event.Str("user", payload.(*token.Payload).Username)
+		}
+
+		if message := recorder.errorMessage(); len(message) > 0 {
+			event = event.Str("error", message)
+		} else if len(ctx.Errors) > 0 {
+			event = event.Str("error", ctx.Errors.String())
+		}
+
+		event.Msg("request")
+	}
+}
+
+// validRequestID accepts short ids made of letters, digits and a few separators
+// anything else could be used to forge log lines
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// errorRecorder keeps the body of error responses, the handlers write the error there with errorResponse
+type errorRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *errorRecorder) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorRecorder) WriteString(data string) (int, error) {
+	w.record([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *errorRecorder) record(data []byte) {
+	if w.Status() < http.StatusBadRequest {
+		return
+	}
+
+	if remaining := maxLoggedErrorSize - w.body.Len(); remaining > 0 {
+		if len(data) > remaining {
+			data = data[:remaining]
+		}
+		w.body.Write(data)
+	}
+}
+
+// errorMessage returns the error of an errorResponse body, or an empty string if there is none
+func (w *errorRecorder) errorMessage() string {
+	var response struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &response); err != nil {
+		return ""
+	}
+	return response.Error
+}
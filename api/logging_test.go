@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/logging"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	account := randomAccount(util.RandomOwner())
+
+	testCases := []struct {
+		name          string
+		requestID     string
+		accountErr    error
+		checkResponse func(t *testing.T, requestID string, line map[string]interface{})
+	}{
+		{
+			name: "NewRequestID",
+			checkResponse: func(t *testing.T, requestID string, line map[string]interface{}) {
+				_, err := uuid.Parse(requestID)
+				require.NoError(t, err)
+
+				require.Equal(t, "info", line["level"])
+				require.Equal(t, float64(http.StatusOK), line["status"])
+				require.NotContains(t, line, "error")
+			},
+		},
+		{
+			name:      "ClientRequestID",
+			requestID: "client-request.1",
+			checkResponse: func(t *testing.T, requestID string, line map[string]interface{}) {
+				require.Equal(t, "client-request.1", requestID)
+			},
+		},
+		{
+			// a request id with a line break could be used to forge log lines
+			name:      "InvalidRequestID",
+			requestID: "bad\nid",
+			checkResponse: func(t *testing.T, requestID string, line map[string]interface{}) {
+				_, err := uuid.Parse(requestID)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:      "TooLongRequestID",
+			requestID: strings.Repeat("a", maxRequestIDLength+1),
+			checkResponse: func(t *testing.T, requestID string, line map[string]interface{}) {
+				_, err := uuid.Parse(requestID)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:       "NotFound",
+			accountErr: sql.ErrNoRows,
+			checkResponse: func(t *testing.T, requestID string, line map[string]interface{}) {
+				require.Equal(t, "warn", line["level"])
+				require.Equal(t, float64(http.StatusNotFound), line["status"])
+				require.Equal(t, sql.ErrNoRows.Error(), line["error"])
+			},
+		},
+		{
+			name:       "InternalError",
+			accountErr: sql.ErrConnDone,
+			checkResponse: func(t *testing.T, requestID string, line map[string]interface{}) {
+				require.Equal(t, "error", line["level"])
+				require.Equal(t, float64(http.StatusInternalServerError), line["status"])
+				require.Equal(t, sql.ErrConnDone.Error(), line["error"])
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// the request id reaches the store through the ctx passed by the handler
+			var storeRequestID string
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).
+				DoAndReturn(func(ctx context.Context, _ int64) (db.Account, error) {
+					storeRequestID = logging.RequestID(ctx)
+					return account, tc.accountErr
+				})
+			store.EXPECT().GetHeldAmount(gomock.Any(), gomock.Any()).AnyTimes().Return(int64(0), nil)
+
+			var logs bytes.Buffer
+			server := newTestServer(t, store)
+			server.logger = zerolog.New(&logs)
+			server.setupRouter()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%d", account.ID), nil)
+			require.NoError(t, err)
+			if len(tc.requestID) > 0 {
+				request.Header.Set(requestIDHeaderKey, tc.requestID)
+			}
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, time.Minute)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+
+			requestID := recorder.Header().Get(requestIDHeaderKey)
+			require.NotEmpty(t, requestID)
+			require.Equal(t, requestID, storeRequestID)
+
+			var line map[string]interface{}
+			err = json.Unmarshal(logs.Bytes(), &line)
+			require.NoError(t, err)
+
+			require.Equal(t, requestID, line[logging.RequestIDField])
+			require.Equal(t, http.MethodGet, line["method"])
+			require.Equal(t, fmt.Sprintf("/accounts/%d", account.ID), line["path"])
+			require.Equal(t, account.Owner, line["user"])
+			require.Contains(t, line, "latency")
+
+			tc.checkResponse(t, requestID, line)
+		})
+	}
+}
+
+func TestLoggingMiddlewareWithoutUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var logs bytes.Buffer
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+	server.logger = zerolog.New(&logs)
+	server.setupRouter()
+
+	// rejected by the auth middleware, so there is no user but there is an error
+	request, err := http.NewRequest(http.MethodGet, "/accounts/1", nil)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	var line map[string]interface{}
+	err = json.Unmarshal(logs.Bytes(), &line)
+	require.NoError(t, err)
+
+	require.NotContains(t, line, "user")
+	require.Equal(t, "authorization header is not provided", line["error"])
+	require.Equal(t, recorder.Header().Get(requestIDHeaderKey), line[logging.RequestIDField])
+}
@@ -3,10 +3,32 @@ package api
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
 )
 
+// newTestServer creates a server with a random token key, so tests dont depend on app.env
+func newTestServer(t *testing.T, store db.Store) *Server {
+	config := util.Config{
+		TokenSymmetricKey:    util.RandomString(32),
+		AccessTokenDuration:  time.Minute,
+		RefreshTokenDuration: time.Hour,
+		FXQuoteDuration:      time.Minute,
+		HoldDuration:         time.Hour,
+	}
+
+	// no fees, the fee tests set their own schedule
+	server, err := NewServer(config, store, nil, nil, zerolog.Nop())
+	require.NoError(t, err)
+
+	return server
+}
+
 func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode)
 	os.Exit(m.Run())
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/metrics"
+)
+
+// metricsMiddleware counts the requests and measures their latency by route
+func metricsMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		// the route pattern is used, e.g. /accounts/:id, unmatched paths share a label so they dont create new series
+		route := ctx.FullPath()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(ctx.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(ctx.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(ctx.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// transferFailureReason returns the metrics reason of an error returned by the transfer transactions
+// or of an account rejected by validAccount or existingAccount
+func transferFailureReason(err error) string {
+	var limitErr *db.LimitError
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return metrics.ReasonNotFound
+	case errors.Is(err, db.ErrInsufficientFunds):
+		return metrics.ReasonInsufficientFunds
+	case errors.Is(err, db.ErrCurrencyMismatch):
+		return metrics.ReasonCurrencyMismatch
+	case errors.Is(err, db.ErrAccountFrozen), errors.Is(err, db.ErrAccountClosed):
+		return metrics.ReasonAccountInactive
+	case errors.As(err, &limitErr):
+		return metrics.ReasonLimitExceeded
+	default:
+		return metrics.ReasonOther
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/metrics"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the request without a token is rejected by the auth middleware, before the store is used
+	account := randomAccount(util.RandomOwner())
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	// metrics are global, so only the change made by this test is checked
+	ok := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/healthz", "200")
+	unauthorized := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/accounts/:id", "401")
+	unmatched := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "unmatched", "404")
+	before := []float64{testutil.ToFloat64(ok), testutil.ToFloat64(unauthorized), testutil.ToFloat64(unmatched)}
+
+	for _, url := range []string{"/healthz", "/healthz", fmt.Sprintf("/accounts/%d", account.ID), "/no/such/path"} {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		server.router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	// the route pattern is the label, not the path with the id
+	require.Equal(t, before[0]+2, testutil.ToFloat64(ok))
+	require.Equal(t, before[1]+1, testutil.ToFloat64(unauthorized))
+	require.Equal(t, before[2]+1, testutil.ToFloat64(unmatched))
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `simplebank_http_requests_total{method="GET",route="/healthz",status="200"}`)
+}
+
+func TestTransferFailureReason(t *testing.T) {
+	testCases := []struct {
+		err    error
+		reason string
+	}{
+		{fmt.Errorf("account [1]: %w", db.ErrInsufficientFunds), metrics.ReasonInsufficientFunds},
+		{db.ErrCurrencyMismatch, metrics.ReasonCurrencyMismatch},
+		{sql.ErrNoRows, metrics.ReasonNotFound},
+		{db.ErrAccountFrozen, metrics.ReasonAccountInactive},
+		{db.ErrAccountClosed, metrics.ReasonAccountInactive},
+		{&db.LimitError{Limit: db.LimitDaily}, metrics.ReasonLimitExceeded},
+		{sql.ErrConnDone, metrics.ReasonOther},
+		{errors.New("unknown"), metrics.ReasonOther},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.reason, transferFailureReason(tc.err), tc.err.Error())
+	}
+}
+
+func TestRejectedAccountMetrics(t *testing.T) {
+	user, _ := randomUser(t)
+	account1 := randomAccount(user.Username)
+	account2 := randomAccount(util.RandomOwner())
+	account1.Currency = util.USD
+	account1.Balance = 1000
+
+	testCases := []struct {
+		name       string
+		currency   string
+		buildStubs func(store *mockdb.MockStore)
+		reason     string
+	}{
+		{
+			name:     "FromAccountNotFound",
+			currency: util.USD,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			reason: metrics.ReasonNotFound,
+		},
+		{
+			name:     "ToAccountNotFound",
+			currency: util.USD,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			reason: metrics.ReasonNotFound,
+		},
+		{
+			name:     "CurrencyMismatch",
+			currency: util.EUR,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+			},
+			reason: metrics.ReasonCurrencyMismatch,
+		},
+		{
+			// the account lookup failed, the account wasnt rejected
+			name:     "InternalError",
+			currency: util.USD,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(db.Account{}, sql.ErrConnDone)
+			},
+			reason: metrics.ReasonOther,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          10,
+				"currency":        tc.currency,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+
+			// metrics are global, so only the change made by the request is checked
+			counter := metrics.TransferFailures.WithLabelValues(tc.reason)
+			before := testutil.ToFloat64(counter)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+}
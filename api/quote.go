@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/token"
+)
+
+type createQuoteRequest struct {
+	FromCurrency string `json:"from_currency" binding:"required,currency"`
+	ToCurrency   string `json:"to_currency" binding:"required,currency,nefield=FromCurrency"`
+	Amount       int64  `json:"amount" binding:"required,gt=0"`
+}
+
+type quoteResponse struct {
+	ID           uuid.UUID `json:"id"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Rate         string    `json:"rate"`
+	Amount       int64     `json:"amount"`
+	ToAmount     int64     `json:"to_amount"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// createQuote returns the current rate for a currency pair
+// the rate is stored, so a transfer sent with the quote id before it expires uses exactly this rate
+func (server *Server) createQuote(ctx *gin.Context) {
+	var req createQuoteRequest
+	// if client provided invalid data
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rate, err := server.rateProvider.GetRate(ctx.Request.Context(), req.FromCurrency, req.ToCurrency)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	toAmount, err := rate.ConvertAmount(req.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	quote, err := server.store.CreateQuote(ctx.Request.Context(), db.CreateQuoteParams{
+		ID:           uuid.New(),
+		Username:     authPayload.Username,
+		FromCurrency: rate.From,
+		ToCurrency:   rate.To,
+		Rate:         rate.Value,
+		ExpiresAt:    time.Now().Add(server.config.FXQuoteDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := quoteResponse{
+		ID:           quote.ID,
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		Rate:         quote.Rate,
+		Amount:       req.Amount,
+		ToAmount:     toAmount,
+		ExpiresAt:    quote.ExpiresAt,
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/keremakillioglu/simplebank/util"
+)
+
+type scheduleAccountRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type scheduleRequest struct {
+	ID         int64 `uri:"id" binding:"required,min=1"`
+	ScheduleID int64 `uri:"schedule_id" binding:"required,min=1"`
+}
+
+// schedules are standing orders of the account in the uri
+// both accounts must have the same currency, no rate can be locked for the future runs
+// interval defaults to 1, end_at and count are optional limits of a recurring schedule
+type createScheduleBody struct {
+	ToAccountID int64     `json:"to_account_id" binding:"required,min=1"`
+	Amount      int64     `json:"amount" binding:"required,gt=0"`
+	Currency    string    `json:"currency" binding:"required,currency"`
+	Frequency   string    `json:"frequency" binding:"required,oneof=once daily weekly monthly"`
+	Interval    int32     `json:"interval" binding:"omitempty,min=1,max=1000"`
+	StartAt     time.Time `json:"start_at" binding:"required"`
+	EndAt       time.Time `json:"end_at"`
+	Count       int32     `json:"count" binding:"omitempty,min=1"`
+}
+
+// scheduleResponse replaces the null columns with omitted fields
+type scheduleResponse struct {
+	ID             int64      `json:"id"`
+	FromAccountID  int64      `json:"from_account_id"`
+	ToAccountID    int64      `json:"to_account_id"`
+	Amount         int64      `json:"amount"`
+	Frequency      string     `json:"frequency"`
+	Interval       int32      `json:"interval"`
+	StartAt        time.Time  `json:"start_at"`
+	EndAt          *time.Time `json:"end_at,omitempty"`
+	Count          *int32     `json:"count,omitempty"`
+	Status         string     `json:"status"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	RunCount       int32      `json:"run_count"`
+	FailedAttempts int32      `json:"failed_attempts"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newScheduleResponse(schedule db.ScheduledTransfer) scheduleResponse {
+	rsp := scheduleResponse{
+		ID:             schedule.ID,
+		FromAccountID:  schedule.FromAccountID,
+		ToAccountID:    schedule.ToAccountID,
+		Amount:         schedule.Amount,
+		Frequency:      schedule.Frequency,
+		Interval:       schedule.RepeatInterval,
+		StartAt:        schedule.StartAt,
+		Status:         schedule.Status,
+		RunCount:       schedule.RunCount,
+		FailedAttempts: schedule.FailedAttempts,
+		CreatedAt:      schedule.CreatedAt,
+	}
+	if schedule.EndAt.Valid {
+		rsp.EndAt = &schedule.EndAt.Time
+	}
+	if schedule.MaxRuns.Valid {
+		rsp.Count = &schedule.MaxRuns.Int32
+	}
+	if schedule.NextRunAt.Valid {
+		rsp.NextRunAt = &schedule.NextRunAt.Time
+	}
+	return rsp
+}
+
+type scheduleRunResponse struct {
+	ID           int64     `json:"id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Attempt      int32     `json:"attempt"`
+	// omitted for failed runs
+	TransferID int64     `json:"transfer_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newScheduleRunResponse(run db.ScheduledTransferRun) scheduleRunResponse {
+	return scheduleRunResponse{
+		ID:           run.ID,
+		ScheduledFor: run.ScheduledFor,
+		Attempt:      run.Attempt,
+		TransferID:   run.TransferID.Int64,
+		Error:        run.Error,
+		CreatedAt:    run.CreatedAt,
+	}
+}
+
+// createSchedule schedules transfers from an account of the user, the first one runs at start_at
+func (server *Server) createSchedule(ctx *gin.Context) {
+	var req scheduleAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body createScheduleBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if body.Interval == 0 {
+		body.Interval = 1
+	}
+
+	recurrence := util.Recurrence{
+		Frequency: body.Frequency,
+		Interval:  int(body.Interval),
+		Start:     body.StartAt,
+		Until:     body.EndAt,
+		Count:     int(body.Count),
+	}
+	if err := recurrence.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// a past start would run all of the missed occurrences at once
+	if body.StartAt.Before(time.Now()) {
+		err := errors.New("start_at must not be in the past")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if body.ToAccountID == req.ID {
+		err := errors.New("cannot schedule transfers to the same account")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	fromAccount, valid := server.ownedAccount(ctx, req.ID, authPayload.Username)
+	if !valid {
+		return
+	}
+
+	if fromAccount.Currency != body.Currency {
+		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", fromAccount.ID, fromAccount.Currency, body.Currency)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, err := server.validAccount(ctx, body.ToAccountID, body.Currency); err != nil {
+		return
+	}
+
+	arg := db.CreateScheduledTransferParams{
+		FromAccountID:  req.ID,
+		ToAccountID:    body.ToAccountID,
+		Amount:         body.Amount,
+		Frequency:      body.Frequency,
+		RepeatInterval: body.Interval,
+		StartAt:        body.StartAt,
+		EndAt:          sql.NullTime{Time: body.EndAt, Valid: !body.EndAt.IsZero()},
+		MaxRuns:        sql.NullInt32{Int32: body.Count, Valid: body.Count > 0},
+		NextRunAt:      sql.NullTime{Time: body.StartAt, Valid: true},
+	}
+
+	schedule, err := server.store.CreateScheduledTransfer(ctx.Request.Context(), arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newScheduleResponse(schedule))
+}
+
+// listSchedules lists the schedules of an account of the user, including the completed and cancelled ones
+func (server *Server) listSchedules(ctx *gin.Context) {
+	var req scheduleAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, valid := server.ownedAccount(ctx, req.ID, authPayload.Username); !valid {
+		return
+	}
+
+	schedules, err := server.store.ListScheduledTransfers(ctx.Request.Context(), req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]scheduleResponse, len(schedules))
+	for i, schedule := range schedules {
+		rsp[i] = newScheduleResponse(schedule)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type getScheduleResponse struct {
+	scheduleResponse
+	Runs []scheduleRunResponse `json:"runs"`
+}
+
+// getSchedule returns a schedule with its runs
+func (server *Server) getSchedule(ctx *gin.Context) {
+	var req scheduleRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	schedule, valid := server.ownedSchedule(ctx, req)
+	if !valid {
+		return
+	}
+
+	runs, err := server.store.ListScheduledTransferRuns(ctx.Request.Context(), schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := getScheduleResponse{
+		scheduleResponse: newScheduleResponse(schedule),
+		Runs:             make([]scheduleRunResponse, len(runs)),
+	}
+	for i, run := range runs {
+		rsp.Runs[i] = newScheduleRunResponse(run)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// fields are optional, the current values are kept for the missing ones
+type updateScheduleBody struct {
+	Amount int64     `json:"amount" binding:"omitempty,gt=0"`
+	EndAt  time.Time `json:"end_at"`
+	Count  int32     `json:"count" binding:"omitempty,min=1"`
+}
+
+// updateSchedule changes the amount or the limits of an active schedule
+// the next run is kept, a schedule that has ended with the new limits is completed by its next run
+func (server *Server) updateSchedule(ctx *gin.Context) {
+	var req scheduleRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body updateScheduleBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	schedule, valid := server.ownedSchedule(ctx, req)
+	if !valid {
+		return
+	}
+
+	arg := db.UpdateScheduledTransferParams{
+		ID:      schedule.ID,
+		Amount:  schedule.Amount,
+		EndAt:   schedule.EndAt,
+		MaxRuns: schedule.MaxRuns,
+	}
+	if body.Amount > 0 {
+		arg.Amount = body.Amount
+	}
+	if !body.EndAt.IsZero() {
+		arg.EndAt = sql.NullTime{Time: body.EndAt, Valid: true}
+	}
+	if body.Count > 0 {
+		arg.MaxRuns = sql.NullInt32{Int32: body.Count, Valid: true}
+	}
+
+	if arg.EndAt.Valid && arg.EndAt.Time.Before(schedule.StartAt) {
+		err := fmt.Errorf("end_at must not be before start_at: %w", util.ErrInvalidRecurrence)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	schedule, err := server.store.UpdateScheduledTransfer(ctx.Request.Context(), arg)
+	if err != nil {
+		handleScheduleChangeError(ctx, req.ScheduleID, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newScheduleResponse(schedule))
+}
+
+// cancelSchedule stops an active schedule, its runs are kept
+func (server *Server) cancelSchedule(ctx *gin.Context) {
+	var req scheduleRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if _, valid := server.ownedSchedule(ctx, req); !valid {
+		return
+	}
+
+	schedule, err := server.store.CancelScheduledTransfer(ctx.Request.Context(), req.ScheduleID)
+	if err != nil {
+		handleScheduleChangeError(ctx, req.ScheduleID, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newScheduleResponse(schedule))
+}
+
+// handleScheduleChangeError writes the response for an error of a schedule update
+// no row is returned for a schedule that is not active anymore, it was found before the update
+func handleScheduleChangeError(ctx *gin.Context, scheduleID int64, err error) {
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("schedule [%d] is not active", scheduleID)
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+}
+
+// ownedSchedule gets the schedule, and writes the error response if it cannot be found under an account of the user
+func (server *Server) ownedSchedule(ctx *gin.Context, req scheduleRequest) (db.ScheduledTransfer, bool) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, valid := server.ownedAccount(ctx, req.ID, authPayload.Username); !valid {
+		return db.ScheduledTransfer{}, false
+	}
+
+	schedule, err := server.store.GetScheduledTransfer(ctx.Request.Context(), req.ScheduleID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return schedule, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return schedule, false
+	}
+
+	// a schedule of another account is reported as missing, like in any other listing
+	if schedule.FromAccountID != req.ID {
+		ctx.JSON(http.StatusNotFound, errorResponse(sql.ErrNoRows))
+		return schedule, false
+	}
+
+	return schedule, true
+}
@@ -1,23 +1,77 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/fee"
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 )
 
 // Server serves HTTP requests for our banking service
 type Server struct {
+	config util.Config
 	// not *dbStore instead bc store is an interface
-	store  db.Store
-	router *gin.Engine
+	store db.Store
+	// interface, so the token type (JWT, PASETO) can be changed without touching the handlers
+	tokenMaker token.Maker
+	// exchange rates for cross currency transfers and quotes
+	rateProvider fx.RateProvider
+	// fees charged on top of the transfers by the store, the server previews them
+	feeSchedule *fee.Schedule
+	// signs the cursors of the paginated lists
+	cursorKey []byte
+	router    *gin.Engine
+	// serves the router, built from the config so it has timeouts and can be shut down
+	httpServer *http.Server
+	// set by Shutdown, readiness fails from then on
+	shuttingDown int32
+	// every request is logged with its request id, see loggingMiddleware
+	logger zerolog.Logger
 }
 
 // NewServer creates  a new HTTP server and setup routing
-func NewServer(store db.Store) *Server {
-	server := &Server{store: store}
-	router := gin.Default()
+// the fee schedule must be the one of the store, the server only previews the fees
+// the rate provider must be the one of the store too, the exchange_rates table is used when it is nil
+func NewServer(config util.Config, store db.Store, feeSchedule *fee.Schedule, rateProvider fx.RateProvider, logger zerolog.Logger) (*Server, error) {
+	tokenMaker, err := newTokenMaker(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token maker: %w", err)
+	}
+
+	if rateProvider == nil {
+		rateProvider = db.NewRateProvider(store)
+	}
+
+	// without a schedule no fees are charged, like a schedule without rules
+	if feeSchedule == nil {
+		feeSchedule, err = fee.NewSchedule(nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create fee schedule: %w", err)
+		}
+	}
+
+	server := &Server{
+		config:       config,
+		store:        store,
+		tokenMaker:   tokenMaker,
+		rateProvider: rateProvider,
+		feeSchedule:  feeSchedule,
+		cursorKey:    newCursorKey(config.TokenSymmetricKey),
+		logger:       logger,
+	}
 
 	// register the custom validator with gin
 	// binding.Validator.Engine() returns a general interface -> convert to validator pointer
@@ -27,34 +81,180 @@ func NewServer(store db.Store) *Server {
 		// binding:"... currency at account.go createAccountRequest param & transfer.go"
 	}
 
-	// route, handlerfunc
+	server.setupRouter()
+
+	// zero timeouts mean no timeout, like the defaults of http.Server
+	server.httpServer = &http.Server{
+		Addr:              config.ServerAddress,
+		Handler:           server.router,
+		ReadHeaderTimeout: config.HTTPReadHeaderTimeout,
+		ReadTimeout:       config.HTTPReadTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+	}
+
+	return server, nil
+}
+
+func (server *Server) setupRouter() {
+	// the logging middleware replaces the text logger of gin.Default, it runs before recovery so recovered panics are logged too
+	// tracing runs first, so the log line of a request has its trace id
+	router := gin.New()
+	router.Use(tracingMiddleware(), loggingMiddleware(server.logger), gin.Recovery(), metricsMiddleware())
+
+	// probes of the orchestrator, they dont need a token
+	router.GET("/healthz", server.healthz)
+	router.GET("/readyz", server.readyz)
+
+	// prometheus scrapes the metrics of the default registry
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	//if we pass multiple parameters: route,middlewares, handlefunc
-	router.POST("/accounts", server.createAccount)
+	router.POST("/newuser", server.createUser)
+
+	// username & password in req body, returns an access token
+	router.POST("/users/login", server.loginUser)
+
+	// refresh token in req body, returns a new access token
+	router.POST("/tokens/renew_access", server.renewAccessToken)
+
+	// every route in this group requires a valid access token
+	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+
+	// route, handlerfunc
+	authRoutes.POST("/accounts", server.createAccount)
 
 	// id is a parameter provided by URI
-	router.GET("/accounts/:id", server.getAccount)
+	authRoutes.GET("/accounts/:id", server.getAccount)
+
+	// parameters will be retrieved from querystring, cursor or the deprecated page_id and page_size
+	authRoutes.GET("/accounts", server.listAccount)
+
+	// from and to dates in querystring, format=csv or format=text for a downloadable statement
+	authRoutes.GET("/accounts/:id/statement", server.getStatement)
+
+	// reason in the optional req body
+	authRoutes.POST("/accounts/:id/freeze", server.freezeAccount)
+	authRoutes.POST("/accounts/:id/unfreeze", server.unfreezeAccount)
+	authRoutes.POST("/accounts/:id/close", server.closeAccount)
+
+	// cursor and page_size in querystring
+	authRoutes.GET("/accounts/:id/entries", server.listEntries)
+
+	// filters in querystring, e.g. ?direction=incoming&min_amount=100&cursor=...
+	authRoutes.GET("/accounts/:id/transfers", server.listAccountTransfers)
 
-	// parameters will be retrieved from querystring
-	router.GET("/accounts", server.listAccount)
+	// standing orders of the account, recurrence in req body
+	authRoutes.POST("/accounts/:id/schedules", server.createSchedule)
+	authRoutes.GET("/accounts/:id/schedules", server.listSchedules)
+	authRoutes.GET("/accounts/:id/schedules/:schedule_id", server.getSchedule)
+	authRoutes.PUT("/accounts/:id/schedules/:schedule_id", server.updateSchedule)
+	authRoutes.DELETE("/accounts/:id/schedules/:schedule_id", server.cancelSchedule)
 
 	// transfer details specified in req body
-	router.POST("/transfers", server.createTransfer)
+	authRoutes.POST("/transfers", server.createTransfer)
 
-	//if we pass multiple parameters: route,middlewares, handlefunc
-	router.POST("/newuser", server.createUser)
+	// visible to the owners of both accounts
+	authRoutes.GET("/transfers/:id", server.getTransfer)
+
+	// optional amount in req body for a partial reversal
+	authRoutes.POST("/transfers/:id/reverse", server.reverseTransfer)
+
+	// from account and legs in req body
+	authRoutes.POST("/transfers/batch", server.createBatchTransfer)
+
+	// currency and amount in querystring, returns the fee a transfer would be charged
+	authRoutes.GET("/fees/preview", server.previewFee)
+
+	// currencies and amount in req body, returns a rate locked for a short time
+	authRoutes.POST("/quotes", server.createQuote)
+
+	// accounts and amount in req body, reserves the amount on the from account
+	authRoutes.POST("/holds", server.createHold)
+
+	// visible to the owners of both accounts
+	authRoutes.GET("/holds/:id", server.getHold)
+
+	// optional amount in req body, the whole hold is captured without it
+	authRoutes.POST("/holds/:id/capture", server.captureHold)
+	authRoutes.POST("/holds/:id/void", server.voidHold)
+
+	// session id is the one returned by login
+	authRoutes.POST("/sessions/:id/revoke", server.revokeSession)
+
+	// only admins can see and change the transfer limits, a missing limit in req body is inherited
+	adminRoutes := router.Group("/admin").Use(authMiddleware(server.tokenMaker), adminMiddleware(server.store))
+	adminRoutes.GET("/limits/default", server.getDefaultLimits)
+	adminRoutes.PUT("/limits/default", server.updateDefaultLimits)
+	adminRoutes.GET("/limits/accounts/:id", server.getAccountLimits)
+	adminRoutes.PUT("/limits/accounts/:id", server.updateAccountLimits)
+	adminRoutes.GET("/limits/users/:username", server.getUserLimits)
+	adminRoutes.PUT("/limits/users/:username", server.updateUserLimits)
 
 	// add routes to router
 	server.router = router
-	return server
+}
+
+// token formats of the config, see newTokenMaker
+const (
+	tokenFormatPaseto = "paseto"
+	tokenFormatJWT    = "jwt"
+)
+
+// newTokenMaker creates the maker of the token format of the config, paseto when none is given
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	switch config.TokenFormat {
+	case "", tokenFormatPaseto:
+		return token.NewPasetoMaker(config.TokenSymmetricKey)
+	case tokenFormatJWT:
+		return token.NewJWTMaker(config.TokenSymmetricKey)
+	default:
+		return nil, fmt.Errorf("invalid token format %q", config.TokenFormat)
+	}
 }
 
 // we had to get access to store object to set new account to database
 
-// Start runs the HTTP Server on a specific address
-// take an address as an input and return an error
-func (server *Server) Start(address string) error {
-	// use the action provided by gin, sinc erouter part is private it cannot be accessed from outside of this api package
-	return server.router.Run(address)
+// Start runs the HTTP Server on the address of the config
+// it blocks until the server fails, or is stopped by Shutdown in which case it returns nil
+func (server *Server) Start() error {
+	listener, err := net.Listen("tcp", server.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	return server.serve(listener)
+}
+
+func (server *Server) serve(listener net.Listener) error {
+	server.logger.Info().Str("address", listener.Addr().String()).Msg("serving HTTP")
+
+	err := server.httpServer.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting new requests and waits for the in-flight ones to finish
+// readiness fails for the shutdown delay before that, so the load balancer stops sending new requests first
+// when ctx is done first, the remaining connections are closed and the error of ctx is returned
+func (server *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&server.shuttingDown, 1)
+
+	if server.config.ShutdownDelay > 0 {
+		timer := time.NewTimer(server.config.ShutdownDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+
+	err := server.httpServer.Shutdown(ctx)
+	if err != nil {
+		server.httpServer.Close()
+	}
+	return err
 }
 
 // returns key-value pair
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerShutdownDrainsRequests(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	started := make(chan struct{})
+	server.router.GET("/slow", func(ctx *gin.Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.serve(listener)
+	}()
+
+	statusCodes := make(chan int, 1)
+	go func() {
+		rsp, err := http.Get(fmt.Sprintf("http://%s/slow", listener.Addr()))
+		if err != nil {
+			statusCodes <- 0
+			return
+		}
+		rsp.Body.Close()
+		statusCodes <- rsp.StatusCode
+	}()
+	<-started
+
+	// the request in flight is finished before shutdown returns
+	err = server.Shutdown(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, <-statusCodes)
+	require.NoError(t, <-served)
+
+	// new requests are refused
+	_, err = http.Get(fmt.Sprintf("http://%s/slow", listener.Addr()))
+	require.Error(t, err)
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server.router.GET("/stuck", func(ctx *gin.Context) {
+		close(started)
+		<-release
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.serve(listener)
+
+	go http.Get(fmt.Sprintf("http://%s/stuck", listener.Addr()))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestNewTokenMaker(t *testing.T) {
+	testCases := []struct {
+		name      string
+		format    string
+		checkType func(t *testing.T, maker token.Maker)
+		isErr     bool
+	}{
+		{
+			name:   "Default",
+			format: "",
+			checkType: func(t *testing.T, maker token.Maker) {
+				require.IsType(t, &token.PasetoMaker{}, maker)
+			},
+		},
+		{
+			name:   "Paseto",
+			format: tokenFormatPaseto,
+			checkType: func(t *testing.T, maker token.Maker) {
+				require.IsType(t, &token.PasetoMaker{}, maker)
+			},
+		},
+		{
+			name:   "JWT",
+			format: tokenFormatJWT,
+			checkType: func(t *testing.T, maker token.Maker) {
+				require.IsType(t, &token.JWTMaker{}, maker)
+			},
+		},
+		{
+			name:   "Invalid",
+			format: "macaroon",
+			isErr:  true,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			maker, err := newTokenMaker(util.Config{TokenFormat: tc.format, TokenSymmetricKey: util.RandomString(32)})
+			if tc.isErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tc.checkType(t, maker)
+
+			// the selected maker verifies the tokens it creates
+			accessToken, _, err := maker.CreateToken(util.RandomOwner(), token.TokenTypeAccess, time.Minute)
+			require.NoError(t, err)
+			_, err = maker.VerifyToken(accessToken, token.TokenTypeAccess)
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/keremakillioglu/simplebank/util"
+)
+
+// supported output formats of the statement
+const (
+	statementFormatJSON = "json"
+	statementFormatCSV  = "csv"
+	statementFormatText = "text"
+)
+
+// statementDateLayout is the layout of the from and to dates, they are interpreted in UTC
+const statementDateLayout = "2006-01-02"
+
+// a statement can cover at most a year, so a single request cannot scan the whole ledger
+const maxStatementPeriod = 366 * 24 * time.Hour
+
+type getStatementRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// both dates are inclusive
+type statementQuery struct {
+	From   time.Time `form:"from" binding:"required" time_format:"2006-01-02" time_utc:"1"`
+	To     time.Time `form:"to" binding:"required" time_format:"2006-01-02" time_utc:"1"`
+	Format string    `form:"format" binding:"omitempty,oneof=json csv text"`
+}
+
+type statementEntry struct {
+	EntryID int64 `json:"entry_id"`
+	// transfer and counterparty are omitted for entries not created by a transfer
+	TransferID            int64     `json:"transfer_id,omitempty"`
+	CounterpartyAccountID int64     `json:"counterparty_account_id,omitempty"`
+	Fee                   bool      `json:"fee,omitempty"`
+	Amount                int64     `json:"amount"`
+	Balance               int64     `json:"balance"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+type statementResponse struct {
+	AccountID      int64            `json:"account_id"`
+	Owner          string           `json:"owner"`
+	Currency       string           `json:"currency"`
+	From           string           `json:"from"`
+	To             string           `json:"to"`
+	OpeningBalance int64            `json:"opening_balance"`
+	ClosingBalance int64            `json:"closing_balance"`
+	Entries        []statementEntry `json:"entries"`
+}
+
+func newStatementResponse(query statementQuery, result db.StatementTxResult) statementResponse {
+	rsp := statementResponse{
+		AccountID:      result.Account.ID,
+		Owner:          result.Account.Owner,
+		Currency:       result.Account.Currency,
+		From:           query.From.Format(statementDateLayout),
+		To:             query.To.Format(statementDateLayout),
+		OpeningBalance: result.OpeningBalance,
+		ClosingBalance: result.ClosingBalance,
+		Entries:        make([]statementEntry, 0, len(result.Lines)),
+	}
+
+	for _, line := range result.Lines {
+		rsp.Entries = append(rsp.Entries, statementEntry{
+			EntryID:               line.EntryID,
+			TransferID:            line.TransferID.Int64,
+			CounterpartyAccountID: line.CounterpartyAccountID.Int64,
+			Fee:                   line.Fee,
+			Amount:                line.Amount,
+			Balance:               line.Balance,
+			CreatedAt:             line.CreatedAt,
+		})
+	}
+
+	return rsp
+}
+
+// getStatement returns the entries of an account between two dates with running balances
+// output is JSON by default, ?format=csv and ?format=text return a downloadable statement
+func (server *Server) getStatement(ctx *gin.Context) {
+	var req getStatementRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var query statementQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if query.To.Before(query.From) {
+		err := errors.New("to date must not be before from date")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if query.To.Sub(query.From) >= maxStatementPeriod {
+		err := fmt.Errorf("statement period must be shorter than %d days", maxStatementPeriod/(24*time.Hour))
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// user can only get the statements of the accounts that he/she owns
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	account, valid := server.ownedAccount(ctx, req.ID, authPayload.Username)
+	if !valid {
+		return
+	}
+
+	// to date is inclusive, so the entries of the whole day are listed
+	result, err := server.store.StatementTx(ctx.Request.Context(), db.StatementTxParams{
+		AccountID: account.ID,
+		From:      query.From,
+		To:        query.To.AddDate(0, 0, 1),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := newStatementResponse(query, result)
+
+	switch query.Format {
+	case statementFormatCSV:
+		data, err := rsp.csv()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		writeStatementFile(ctx, rsp, "csv", "text/csv; charset=utf-8", data)
+	case statementFormatText:
+		writeStatementFile(ctx, rsp, "txt", "text/plain; charset=utf-8", rsp.text())
+	default:
+		ctx.JSON(http.StatusOK, rsp)
+	}
+}
+
+// writeStatementFile sends the statement as a file attachment
+func writeStatementFile(ctx *gin.Context, rsp statementResponse, extension string, contentType string, data []byte) {
+	filename := fmt.Sprintf("statement-%d-%s-%s.%s", rsp.AccountID, rsp.From, rsp.To, extension)
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// csv writes a row per entry between the opening and closing balance rows
+// amounts are decimals in the currency of the account, e.g. 12.34
+func (rsp statementResponse) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	records := [][]string{
+		{"date", "description", "entry_id", "transfer_id", "counterparty_account_id", "amount", "balance"},
+		{rsp.From, "opening balance", "", "", "", "", formatAmount(rsp.OpeningBalance, rsp.Currency)},
+	}
+
+	for _, entry := range rsp.Entries {
+		records = append(records, []string{
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			entry.description(),
+			strconv.FormatInt(entry.EntryID, 10),
+			formatID(entry.TransferID),
+			formatID(entry.CounterpartyAccountID),
+			formatAmount(entry.Amount, rsp.Currency),
+			formatAmount(entry.Balance, rsp.Currency),
+		})
+	}
+
+	records = append(records, []string{rsp.To, "closing balance", "", "", "", "", formatAmount(rsp.ClosingBalance, rsp.Currency)})
+
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// text formats the statement as a plain text table for printing
+func (rsp statementResponse) text() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Statement of account %d (%s)\n", rsp.AccountID, rsp.Currency)
+	fmt.Fprintf(&buf, "Owner: %s\n", rsp.Owner)
+	fmt.Fprintf(&buf, "Period: %s - %s\n\n", rsp.From, rsp.To)
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DATE\tDESCRIPTION\tAMOUNT\tBALANCE\t")
+	fmt.Fprintf(w, "%s\topening balance\t\t%s\t\n", rsp.From, formatAmount(rsp.OpeningBalance, rsp.Currency))
+	for _, entry := range rsp.Entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n",
+			entry.CreatedAt.UTC().Format("2006-01-02 15:04:05"),
+			entry.description(),
+			formatAmount(entry.Amount, rsp.Currency),
+			formatAmount(entry.Balance, rsp.Currency))
+	}
+	fmt.Fprintf(w, "%s\tclosing balance\t\t%s\t\n", rsp.To, formatAmount(rsp.ClosingBalance, rsp.Currency))
+	w.Flush()
+
+	return buf.Bytes()
+}
+
+func (entry statementEntry) description() string {
+	switch {
+	case entry.TransferID == 0:
+		return fmt.Sprintf("entry %d", entry.EntryID)
+	case entry.Fee && entry.Amount < 0:
+		return fmt.Sprintf("fee for transfer %d", entry.TransferID)
+	case entry.Fee:
+		return fmt.Sprintf("fee for transfer %d from account %d", entry.TransferID, entry.CounterpartyAccountID)
+	case entry.Amount < 0:
+		return fmt.Sprintf("transfer %d to account %d", entry.TransferID, entry.CounterpartyAccountID)
+	default:
+		return fmt.Sprintf("transfer %d from account %d", entry.TransferID, entry.CounterpartyAccountID)
+	}
+}
+
+// formatAmount formats an amount as a decimal in the minor units of the currency
+// accounts in a currency that was removed from the registry show the raw amount
+func formatAmount(amount int64, currency string) string {
+	money, err := util.NewMoney(amount, currency)
+	if err != nil {
+		return strconv.FormatInt(amount, 10)
+	}
+	return money.Decimal()
+}
+
+// formatID leaves missing ids empty
+func formatID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStatementAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	account.Currency = util.USD
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	result := db.StatementTxResult{
+		Account:        account,
+		OpeningBalance: 1000,
+		ClosingBalance: 1150,
+		Lines: []db.StatementLine{
+			{
+				EntryID:               1,
+				TransferID:            sql.NullInt64{Int64: 10, Valid: true},
+				CounterpartyAccountID: sql.NullInt64{Int64: 20, Valid: true},
+				Amount:                -50,
+				Balance:               950,
+				CreatedAt:             from.Add(time.Hour),
+			},
+			{
+				EntryID:               2,
+				TransferID:            sql.NullInt64{Int64: 10, Valid: true},
+				CounterpartyAccountID: sql.NullInt64{Int64: 30, Valid: true},
+				Fee:                   true,
+				Amount:                -5,
+				Balance:               945,
+				CreatedAt:             from.Add(time.Hour),
+			},
+			{
+				EntryID:               3,
+				TransferID:            sql.NullInt64{Int64: 11, Valid: true},
+				CounterpartyAccountID: sql.NullInt64{Int64: 21, Valid: true},
+				Amount:                205,
+				Balance:               1150,
+				CreatedAt:             from.Add(2 * time.Hour),
+			},
+		},
+	}
+
+	// to date is inclusive
+	expectedArg := db.StatementTxParams{
+		AccountID: account.ID,
+		From:      from,
+		To:        to.AddDate(0, 0, 1),
+	}
+
+	testCases := []struct {
+		name          string
+		accountID     int64
+		query         string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			accountID: account.ID,
+			query:     "from=2021-03-01&to=2021-03-31",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Eq(expectedArg)).Times(1).Return(result, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp statementResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+
+				require.Equal(t, account.ID, rsp.AccountID)
+				require.Equal(t, "2021-03-01", rsp.From)
+				require.Equal(t, "2021-03-31", rsp.To)
+				require.Equal(t, int64(1000), rsp.OpeningBalance)
+				require.Equal(t, int64(1150), rsp.ClosingBalance)
+				require.Len(t, rsp.Entries, 3)
+				require.Equal(t, int64(20), rsp.Entries[0].CounterpartyAccountID)
+				require.Equal(t, int64(950), rsp.Entries[0].Balance)
+				require.False(t, rsp.Entries[0].Fee)
+				// fee of the transfer is charged by the fee account, not the recipient
+				require.True(t, rsp.Entries[1].Fee)
+				require.Equal(t, int64(30), rsp.Entries[1].CounterpartyAccountID)
+				require.Equal(t, int64(945), rsp.Entries[1].Balance)
+				require.Equal(t, int64(1150), rsp.Entries[2].Balance)
+			},
+		},
+		{
+			name:      "CSV",
+			accountID: account.ID,
+			query:     "from=2021-03-01&to=2021-03-31&format=csv",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Eq(expectedArg)).Times(1).Return(result, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Header().Get("Content-Type"), "text/csv")
+				require.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+
+				records, err := csv.NewReader(recorder.Body).ReadAll()
+				require.NoError(t, err)
+				// header, opening balance, 3 entries, closing balance
+				require.Len(t, records, 6)
+				require.Equal(t, []string{"2021-03-01", "opening balance", "", "", "", "", "10.00"}, records[1])
+				require.Equal(t, []string{"2021-03-01T01:00:00Z", "transfer 10 to account 20", "1", "10", "20", "-0.50", "9.50"}, records[2])
+				require.Equal(t, []string{"2021-03-01T01:00:00Z", "fee for transfer 10", "2", "10", "30", "-0.05", "9.45"}, records[3])
+				require.Equal(t, []string{"2021-03-31", "closing balance", "", "", "", "", "11.50"}, records[5])
+			},
+		},
+		{
+			name:      "TEXT",
+			accountID: account.ID,
+			query:     "from=2021-03-01&to=2021-03-31&format=text",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Eq(expectedArg)).Times(1).Return(result, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+
+				body := recorder.Body.String()
+				require.Contains(t, body, fmt.Sprintf("Statement of account %d (USD)", account.ID))
+				require.Contains(t, body, "transfer 11 from account 21")
+				require.Contains(t, body, "11.50")
+			},
+		},
+		{
+			name:      "UNAUTHORIZEDUSER",
+			accountID: account.ID,
+			query:     "from=2021-03-01&to=2021-03-31",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "NOTFOUND",
+			accountID: account.ID,
+			query:     "from=2021-03-01&to=2021-03-31",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:      "INTERNALERROR",
+			accountID: account.ID,
+			query:     "from=2021-03-01&to=2021-03-31",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Any()).Times(1).Return(db.StatementTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:      "INVALIDRANGE",
+			accountID: account.ID,
+			query:     "from=2021-03-31&to=2021-03-01",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "PERIODTOOLONG",
+			accountID: account.ID,
+			query:     "from=2020-01-01&to=2021-03-01",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "MISSINGDATES",
+			accountID: account.ID,
+			query:     "format=csv",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "INVALIDFORMAT",
+			accountID: account.ID,
+			query:     "from=2021-03-01&to=2021-03-31&format=pdf",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/statement?%s", tc.accountID, tc.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewAccessTokenAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name string
+		// builds the refresh token and the session stored for it
+		buildSession  func(t *testing.T, tokenMaker token.Maker) (string, db.Session)
+		buildStubs    func(store *mockdb.MockStore, session db.Session)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildSession: func(t *testing.T, tokenMaker token.Maker) (string, db.Session) {
+				return randomSession(t, tokenMaker, user.Username)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp renewAccessTokenResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.AccessToken)
+			},
+		},
+		{
+			name: "BLOCKEDSESSION",
+			buildSession: func(t *testing.T, tokenMaker token.Maker) (string, db.Session) {
+				refreshToken, session := randomSession(t, tokenMaker, user.Username)
+				session.IsBlocked = true
+				return refreshToken, session
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "MISMATCHEDTOKEN",
+			buildSession: func(t *testing.T, tokenMaker token.Maker) (string, db.Session) {
+				refreshToken, session := randomSession(t, tokenMaker, user.Username)
+				session.RefreshToken = "another-token"
+				return refreshToken, session
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "SESSIONNOTFOUND",
+			buildSession: func(t *testing.T, tokenMaker token.Maker) (string, db.Session) {
+				return randomSession(t, tokenMaker, user.Username)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "ACCESSTOKEN",
+			buildSession: func(t *testing.T, tokenMaker token.Maker) (string, db.Session) {
+				// an access token cannot renew itself
+				accessToken, _, err := tokenMaker.CreateToken(user.Username, token.TokenTypeAccess, time.Hour)
+				require.NoError(t, err)
+				return accessToken, db.Session{}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "INVALIDTOKEN",
+			buildSession: func(t *testing.T, tokenMaker token.Maker) (string, db.Session) {
+				return "invalid-token", db.Session{}
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			refreshToken, session := tc.buildSession(t, server.tokenMaker)
+			tc.buildStubs(store, session)
+
+			recorder := httptest.NewRecorder()
+
+			// marshal body data to JSON
+			data, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/tokens/renew_access", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// randomSession creates a refresh token and a matching session, like loginUser does
+func randomSession(t *testing.T, tokenMaker token.Maker, username string) (string, db.Session) {
+	refreshToken, payload, err := tokenMaker.CreateToken(username, token.TokenTypeRefresh, time.Hour)
+	require.NoError(t, err)
+
+	session := db.Session{
+		ID:           payload.ID,
+		Username:     username,
+		RefreshToken: refreshToken,
+		ExpiresAt:    payload.ExpiredAt,
+	}
+	return refreshToken, session
+}
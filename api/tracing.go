@@ -0,0 +1,54 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/keremakillioglu/simplebank/logging"
+	"github.com/keremakillioglu/simplebank/token"
+	"github.com/keremakillioglu/simplebank/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware runs every request in a server span, continuing the trace of the traceparent header if there is one
+// the store queries of the handlers are the children of the span, they get it with the request context
+func tracingMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		parent := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		// the route pattern is used like in metricsMiddleware, so the spans of a route are grouped together
+		route := ctx.FullPath()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+
+		spanCtx, span := tracing.Tracer().Start(parent, ctx.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("simplebank", route, ctx.Request)...),
+		)
+		defer span.End()
+
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+
+		// the params of the route, e.g. the account id of /accounts/:id
+		for _, param := range ctx.Params {
+			span.SetAttributes(attribute.String("http.route.param."+param.Key, param.Value))
+		}
+
+		if requestID := logging.RequestID(ctx.Request.Context()); len(requestID) > 0 {
+			span.SetAttributes(attribute.String(logging.RequestIDField, requestID))
+		}
+
+		if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+			span.SetAttributes(semconv.EnduserIDKey.String(payload.(*token.Payload).Username))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	account := randomAccount(util.RandomOwner())
+
+	testCases := []struct {
+		name        string
+		traceparent string
+		checkSpan   func(t *testing.T, span *sdktrace.SpanSnapshot)
+	}{
+		{
+			name:        "RemoteParent",
+			traceparent: fmt.Sprintf("00-%s-%s-01", testTraceID, testSpanID),
+			checkSpan: func(t *testing.T, span *sdktrace.SpanSnapshot) {
+				require.Equal(t, testTraceID, span.SpanContext.TraceID().String())
+				require.Equal(t, testSpanID, span.Parent.SpanID().String())
+				require.True(t, span.Parent.IsRemote())
+			},
+		},
+		{
+			name: "NewTrace",
+			checkSpan: func(t *testing.T, span *sdktrace.SpanSnapshot) {
+				require.False(t, span.Parent.IsValid())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.Reset()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// the store gets the span of the request through the request context
+			var storeSpan trace.SpanContext
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).
+				DoAndReturn(func(ctx context.Context, _ int64) (db.Account, error) {
+					storeSpan = trace.SpanFromContext(ctx).SpanContext()
+					return account, nil
+				})
+			store.EXPECT().GetHeldAmount(gomock.Any(), gomock.Any()).AnyTimes().Return(int64(0), nil)
+
+			server := newTestServer(t, store)
+
+			url := fmt.Sprintf("/accounts/%d", account.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			if len(tc.traceparent) > 0 {
+				request.Header.Set("traceparent", tc.traceparent)
+			}
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, time.Minute)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			span := spans[0]
+
+			require.Equal(t, "GET /accounts/:id", span.Name)
+			require.Equal(t, trace.SpanKindServer, span.SpanKind)
+			require.Equal(t, span.SpanContext.SpanID(), storeSpan.SpanID())
+
+			attributes := make(map[attribute.Key]attribute.Value)
+			for _, kv := range span.Attributes {
+				attributes[kv.Key] = kv.Value
+			}
+			require.Equal(t, int64(http.StatusOK), attributes["http.status_code"].AsInt64())
+			require.Equal(t, "/accounts/:id", attributes["http.route"].AsString())
+			require.Equal(t, fmt.Sprint(account.ID), attributes["http.route.param.id"].AsString())
+			require.Equal(t, account.Owner, attributes["enduser.id"].AsString())
+			require.Equal(t, recorder.Header().Get(requestIDHeaderKey), attributes["request_id"].AsString())
+
+			tc.checkSpan(t, span)
+		})
+	}
+}
@@ -2,19 +2,30 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/metrics"
+	"github.com/keremakillioglu/simplebank/token"
 )
 
 // balance is zero initially
 type transferRequest struct {
-	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
-	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1"`
-	Amount        int64  `json:"amount" binding:"required,gt=0"`
-	Currency      string `json:"currency" binding:"required,currency"`
+	FromAccountID int64 `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64 `json:"to_account_id" binding:"required,min=1"`
+	Amount        int64 `json:"amount" binding:"required,gt=0"`
+	// currency of the amount, it must be the currency of the from account
+	Currency string `json:"currency" binding:"required,currency"`
+	// optional, locks the rate of a quote for a cross currency transfer
+	QuoteID string `json:"quote_id" binding:"omitempty,uuid"`
 }
 
 // in gin, everything we do includes a context object
@@ -28,26 +39,88 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		return
 	}
 
-	if !server.validAccount(ctx, req.FromAccountID, req.Currency) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	// a retried request is answered with the stored response, before any of the checks below
+	// otherwise e.g. the balance check could fail because of the first transfer itself
+	idem, err := newIdempotencyParams(ctx, authPayload.Username, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if idem != nil && server.replayIdempotentRequest(ctx, *idem) {
+		return
+	}
+
+	fromAccount, err := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if err != nil {
+		metrics.TransferFailed(transferFailureReason(err))
+		return
+	}
+
+	// user can only send money from his/her own account
+	if fromAccount.Owner != authPayload.Username {
+		err := errors.New("from account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	// fee is charged in the currency of the from account, on top of the amount
+	// the store charges it, the quote is only used to fail fast
+	feeQuote, err := server.feeSchedule.Quote(fromAccount.Currency, req.Amount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	if !server.validAccount(ctx, req.ToAccountID, req.Currency) {
+	// fail fast, TransferTx checks the balance again atomically within the db transaction
+	if fromAccount.Balance+fromAccount.OverdraftLimit < req.Amount+feeQuote.Fee {
+		err := fmt.Errorf("account [%d]: %w", fromAccount.ID, db.ErrInsufficientFunds)
+		metrics.TransferFailed(metrics.ReasonInsufficientFunds)
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
 		return
 	}
 
+	// to account can have another currency, the amount is converted in that case
+	toAccount, err := server.existingAccount(ctx, req.ToAccountID)
+	if err != nil {
+		metrics.TransferFailed(transferFailureReason(err))
+		return
+	}
+
+	var rate fx.Rate
+	if toAccount.Currency != fromAccount.Currency {
+		var valid bool
+		rate, valid = server.exchangeRate(ctx, req.QuoteID, authPayload.Username, fromAccount.Currency, toAccount.Currency)
+		if !valid {
+			return
+		}
+	}
+
 	//if no errors
 	arg := db.TransferTxParams{
 		FromAccountID: req.FromAccountID,
 		ToAccountID:   req.ToAccountID,
 		Amount:        req.Amount,
+		ExchangeRate:  rate,
+	}
+
+	// key and response are stored in the same db transaction as the transfer
+	if idem != nil {
+		result, err := server.store.IdempotentTransferTx(ctx.Request.Context(), *idem, arg)
+		if err != nil {
+			handleTransferError(ctx, err)
+			return
+		}
+
+		writeIdempotentResponse(ctx, result)
+		return
 	}
 
 	// return transaction & error
-	result, err := server.store.TransferTx(ctx, arg)
+	result, err := server.store.TransferTx(ctx.Request.Context(), arg)
 	if err != nil {
-		// internal error (code 500), error message
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		handleTransferError(ctx, err)
 		return
 	}
 
@@ -57,26 +130,372 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 
 }
 
-func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) bool {
+// handleTransferError writes the response for an error returned by the transfer transactions
+func handleTransferError(ctx *gin.Context, err error) {
+	metrics.TransferFailed(transferFailureReason(err))
+
+	var limitErr *db.LimitError
+
+	switch {
+	// balance might have changed since the check in the handler
+	case errors.Is(err, db.ErrInsufficientFunds):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+	// frozen and closed accounts cannot send or receive money
+	case errors.Is(err, db.ErrAccountFrozen), errors.Is(err, db.ErrAccountClosed):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+	// the name of the limit is sent, so the client can tell which one is hit
+	case errors.As(err, &limitErr):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "limit": limitErr.Limit})
+	// a concurrent request used the same key with a different body
+	case errors.Is(err, db.ErrIdempotencyKeyReused):
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+	case errors.Is(err, db.ErrCurrencyMismatch), errors.Is(err, fx.ErrAmountTooSmall):
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+	// the totals of a user limit are converted, see db.ListOwnerOutgoingTransferTotals
+	case errors.Is(err, fx.ErrRateNotFound):
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+	default:
+		// internal error (code 500), error message
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+}
 
-	account, err := server.store.GetAccount(ctx, accountID)
+// exchangeRate returns the rate locked by the quote, or the current rate of the provider if no quote is given
+func (server *Server) exchangeRate(ctx *gin.Context, quoteID string, username string, from string, to string) (fx.Rate, bool) {
+	if len(quoteID) == 0 {
+		rate, err := server.rateProvider.GetRate(ctx.Request.Context(), from, to)
+		if err != nil {
+			// currency pair is not supported
+			if errors.Is(err, fx.ErrRateNotFound) {
+				ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+				return rate, false
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return rate, false
+		}
+		return rate, true
+	}
+
+	// already validated by the binding, so parsing cannot fail
+	quote, err := server.store.GetQuote(ctx.Request.Context(), uuid.MustParse(quoteID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return fx.Rate{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return fx.Rate{}, false
+	}
+
+	if quote.Username != username {
+		err := fmt.Errorf("quote [%s] doesn't belong to the authenticated user", quote.ID)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return fx.Rate{}, false
+	}
+
+	if quote.FromCurrency != from || quote.ToCurrency != to {
+		err := fmt.Errorf("quote [%s] is for %s->%s, not %s->%s", quote.ID, quote.FromCurrency, quote.ToCurrency, from, to)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return fx.Rate{}, false
+	}
+
+	if time.Now().After(quote.ExpiresAt) {
+		err := fmt.Errorf("quote [%s] has expired", quote.ID)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return fx.Rate{}, false
+	}
+
+	return fx.Rate{From: quote.FromCurrency, To: quote.ToCurrency, Value: quote.Rate}, true
+}
+
+// existingAccount gets the account, and writes the error response if it cannot be found
+// the returned error is the cause of the response, e.g. sql.ErrNoRows
+func (server *Server) existingAccount(ctx *gin.Context, accountID int64) (db.Account, error) {
+
+	account, err := server.store.GetAccount(ctx.Request.Context(), accountID)
 
 	if err != nil {
 		//if no such account exists
 		if err == sql.ErrNoRows {
 			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return false
+			return account, err
 		}
 
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return false
+		return account, err
+	}
+
+	return account, nil
+}
+
+// validAccount gets the account like existingAccount, and writes the error response if it is in another currency
+// the returned error wraps db.ErrCurrencyMismatch then
+func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, error) {
+
+	account, err := server.existingAccount(ctx, accountID)
+	if err != nil {
+		return account, err
 	}
 
 	if account.Currency != currency {
-		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", accountID, account.Currency, currency)
+		err := fmt.Errorf("account [%d] %w: %s vs %s", accountID, db.ErrCurrencyMismatch, account.Currency, currency)
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return false
+		return account, err
+	}
+
+	return account, nil
+}
+
+// ownedAccount gets the account, and writes the error response if it cannot be found or belongs to someone else
+func (server *Server) ownedAccount(ctx *gin.Context, accountID int64, username string) (db.Account, bool) {
+	account, err := server.existingAccount(ctx, accountID)
+	if err != nil {
+		return account, false
+	}
+
+	if account.Owner != username {
+		err := errors.New("account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return account, false
+	}
+
+	return account, true
+}
+
+type getTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getTransfer returns a transfer if the user owns one of its accounts
+func (server *Server) getTransfer(ctx *gin.Context) {
+	var req getTransferRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	transfer, err := server.store.GetTransfer(ctx.Request.Context(), req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	// both the sender and the receiver can see the transfer
+	for _, accountID := range []int64{transfer.FromAccountID, transfer.ToAccountID} {
+		account, err := server.existingAccount(ctx, accountID)
+		if err != nil {
+			return
+		}
+		if account.Owner == authPayload.Username {
+			ctx.JSON(http.StatusOK, transfer)
+			return
+		}
+	}
+
+	err = errors.New("transfer doesn't belong to the authenticated user")
+	ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+}
+
+// amount is optional, the remaining amount of the transfer is reversed without it
+type reverseTransferBody struct {
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+// reverseTransfer sends the money of a transfer back to the sender
+// only the owner of the to account can reverse a transfer, since the money is taken from that account
+func (server *Server) reverseTransfer(ctx *gin.Context) {
+	var req getTransferRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var body reverseTransferBody
+	if err := ctx.ShouldBindJSON(&body); err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	transfer, err := server.store.GetTransfer(ctx.Request.Context(), req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, valid := server.ownedAccount(ctx, transfer.ToAccountID, authPayload.Username); !valid {
+		return
+	}
+
+	result, err := server.store.ReverseTransferTx(ctx.Request.Context(), db.ReverseTransferTxParams{
+		TransferID: req.ID,
+		Amount:     body.Amount,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrTransferAlreadyReversed):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		case errors.Is(err, db.ErrReversalExceedsTransfer), errors.Is(err, db.ErrReversalOfReversal):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		default:
+			// the reversal is a transfer from the receiver, e.g. it might not have enough funds anymore
+			handleTransferError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// directions of the transfers of an account
+const (
+	directionIncoming = "incoming"
+	directionOutgoing = "outgoing"
+	directionBoth     = "both"
+)
+
+// end of the time range when no end_time is given, far enough to include every transfer
+var maxTransferTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+type listAccountTransfersRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// all filters are optional
+// amounts are in the currency of the account, times are RFC3339
+// transfers are listed newest first, cursor is the next_cursor of the previous page
+// before_id is deprecated, the cursor should be used instead
+type listAccountTransfersQuery struct {
+	Direction      string    `form:"direction" binding:"omitempty,oneof=incoming outgoing both"`
+	StartTime      time.Time `form:"start_time"`
+	EndTime        time.Time `form:"end_time"`
+	MinAmount      int64     `form:"min_amount" binding:"omitempty,min=1"`
+	MaxAmount      int64     `form:"max_amount" binding:"omitempty,min=1"`
+	CounterpartyID int64     `form:"counterparty_id" binding:"omitempty,min=1"`
+	BeforeID       int64     `form:"before_id" binding:"omitempty,min=1"`
+	Cursor         string    `form:"cursor"`
+	PageSize       int32     `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type listAccountTransfersResponse struct {
+	Transfers  []db.Transfer `json:"transfers"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	HasMore    bool          `json:"has_more"`
+}
+
+// listAccountTransfers lists the transfers sent or received by an account of the user
+func (server *Server) listAccountTransfers(ctx *gin.Context) {
+	var req listAccountTransfersRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var query listAccountTransfersQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	arg, err := newListAccountTransfersParams(req.ID, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	list := fmt.Sprintf("transfers:%d", req.ID)
+	if len(query.Cursor) > 0 {
+		cursor, err := server.decodeCursor(list, query.Cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		arg.BeforeCreatedAt = cursor.CreatedAt
+		arg.BeforeID = cursor.ID
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if _, valid := server.ownedAccount(ctx, req.ID, authPayload.Username); !valid {
+		return
+	}
+
+	if len(query.Cursor) == 0 && query.BeforeID > 0 {
+		// position of the deprecated before_id is only known with the time of the transfer
+		before, err := server.store.GetTransfer(ctx.Request.Context(), query.BeforeID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				err := fmt.Errorf("before_id: transfer [%d] not found", query.BeforeID)
+				ctx.JSON(http.StatusBadRequest, errorResponse(err))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		arg.BeforeCreatedAt = before.CreatedAt
+		arg.BeforeID = before.ID
+		ctx.Header("Deprecation", "true")
+	}
+
+	transfers, err := server.store.ListAccountTransfers(ctx.Request.Context(), arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := listAccountTransfersResponse{Transfers: transfers}
+	if len(transfers) == int(arg.Limit) {
+		// the extra row only tells that there is a next page
+		rsp.Transfers = transfers[:len(transfers)-1]
+		last := rsp.Transfers[len(rsp.Transfers)-1]
+		rsp.NextCursor = server.encodeCursor(list, last.ID, last.CreatedAt)
+		rsp.HasMore = true
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// newListAccountTransfersParams fills the missing filters with values that match every transfer
+func newListAccountTransfersParams(accountID int64, query listAccountTransfersQuery) (db.ListAccountTransfersParams, error) {
+	arg := db.ListAccountTransfersParams{
+		AccountID:       accountID,
+		Incoming:        query.Direction != directionOutgoing,
+		Outgoing:        query.Direction != directionIncoming,
+		FromTime:        query.StartTime,
+		ToTime:          query.EndTime,
+		MinAmount:       query.MinAmount,
+		MaxAmount:       query.MaxAmount,
+		CounterpartyID:  query.CounterpartyID,
+		BeforeCreatedAt: maxTransferTime,
+		BeforeID:        math.MaxInt64,
+		Limit:           pageLimit(query.PageSize),
+	}
+
+	if arg.ToTime.IsZero() {
+		arg.ToTime = maxTransferTime
+	}
+	if arg.MaxAmount == 0 {
+		arg.MaxAmount = math.MaxInt64
+	}
+
+	if !arg.ToTime.After(arg.FromTime) {
+		return arg, errors.New("end_time must be after start_time")
+	}
+	if arg.MaxAmount < arg.MinAmount {
+		return arg, errors.New("max_amount must not be less than min_amount")
+	}
+	if arg.CounterpartyID == accountID {
+		return arg, errors.New("counterparty_id must be another account")
 	}
 
-	return true
+	return arg, nil
 }
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/keremakillioglu/simplebank/token"
 	"github.com/keremakillioglu/simplebank/util"
 
 	"github.com/lib/pq"
@@ -11,6 +14,7 @@ import (
 	db "github.com/keremakillioglu/simplebank/db/sqlc"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type createUserRequest struct {
@@ -20,7 +24,7 @@ type createUserRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 }
 
-type createUserResponse struct {
+type userResponse struct {
 	Username          string    `json:"username"`
 	FullName          string    `json:"full_name"`
 	Email             string    `json:"email"`
@@ -28,6 +32,17 @@ type createUserResponse struct {
 	CreatedAt         time.Time `json:"created_at"`
 }
 
+// hashed password must never be sent back to the client
+func newUserResponse(user db.User) userResponse {
+	return userResponse{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		PasswordChangedAt: user.PasswordChangedAt,
+		CreatedAt:         user.CreatedAt,
+	}
+}
+
 // in gin, everything we do includes a context object
 // handlerFunc  involves *Context input
 func (server *Server) createUser(ctx *gin.Context) {
@@ -55,7 +70,7 @@ func (server *Server) createUser(ctx *gin.Context) {
 	}
 
 	// return created account in db & error
-	user, err := server.store.CreateUser(ctx, arg)
+	user, err := server.store.CreateUser(ctx.Request.Context(), arg)
 	if err != nil {
 
 		if pqErr, ok := err.(*pq.Error); ok {
@@ -71,14 +86,96 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
-	response := createUserResponse{
-		Username:          user.Username,
-		FullName:          user.FullName,
-		Email:             user.Email,
-		PasswordChangedAt: user.PasswordChangedAt,
-		CreatedAt:         user.CreatedAt,
+	// successfully created the account
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// errIncorrectCredentials is returned for both an unknown username and a wrong password
+var errIncorrectCredentials = errors.New("incorrect username or password")
+
+// dummyHashedPassword is compared with the password of an unknown username, so the login takes as long as with a known one
+// it is the bcrypt hash of a random password with the cost of util.HashPassword, hardcoded so it cannot be missing
+const dummyHashedPassword = "$2a$10$Pvkt1SYFEbWOWACat/KzZ.c8Mm8fBCEIz.p4mQ5enIh1CkSI.YLIW"
+
+type loginUserRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type loginUserResponse struct {
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
+}
+
+func (server *Server) loginUser(ctx *gin.Context) {
+	var req loginUserRequest
+	// if client provided invalid data
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
 	}
 
-	// successfully created the account
-	ctx.JSON(http.StatusOK, response)
+	user, err := server.store.GetUser(ctx.Request.Context(), req.Username)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// an unknown username is compared with a dummy hash, so it takes as long as a wrong password
+	// and gets the same response, otherwise the usernames could be enumerated
+	userFound := err == nil
+	hashedPassword := user.HashedPassword
+	if !userFound {
+		hashedPassword = dummyHashedPassword
+	}
+
+	// compare provided password with the stored bcrypt hash
+	err = util.CheckPassword(req.Password, hashedPassword)
+	if err != nil || !userFound {
+		// status unauthorized (code 401)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errIncorrectCredentials))
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, token.TokenTypeAccess, server.config.AccessTokenDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// refresh token lives longer, it is used to get a new access token when the old one expires
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(user.Username, token.TokenTypeRefresh, server.config.RefreshTokenDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// session id is the same as the refresh token id, so that the session can be found when renewing
+	session, err := server.store.CreateSession(ctx.Request.Context(), db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := loginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
+	}
+	ctx.JSON(http.StatusOK, rsp)
 }
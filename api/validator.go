@@ -11,7 +11,7 @@ var validCurrency validator.Func = func(fieldLevel validator.FieldLevel) bool {
 	// extract the currency from field level
 	// fieldLevel.Field() is a reflection value/ call it with .Interface() to get the value and convert it to string
 	if currency, ok := fieldLevel.Field().Interface().(string); ok {
-		//check if currency is supported
+		//check if currency is in the currency registry
 		return util.IsSupportedCurrency(currency)
 	}
 
@@ -0,0 +1,34 @@
+CREATE TABLE "exchange_rates" (
+  "from_currency" varchar NOT NULL,
+  "to_currency" varchar NOT NULL,
+  "rate" numeric(20, 8) NOT NULL,
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("from_currency", "to_currency"),
+  CONSTRAINT "rate_positive" CHECK ("rate" > 0)
+);
+
+CREATE TABLE "quotes" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "from_currency" varchar NOT NULL,
+  "to_currency" varchar NOT NULL,
+  "rate" numeric(20, 8) NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "quotes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "transfers" ADD COLUMN "to_amount" bigint;
+
+UPDATE "transfers" SET "to_amount" = "amount";
+
+ALTER TABLE "transfers" ALTER COLUMN "to_amount" SET NOT NULL;
+
+ALTER TABLE "transfers" ADD COLUMN "exchange_rate" numeric(20, 8) NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN "exchange_rates"."rate" IS 'amount of to_currency paid for one unit of from_currency';
+
+COMMENT ON COLUMN "transfers"."to_amount" IS 'amount credited in the currency of to account';
+
+COMMENT ON COLUMN "transfers"."exchange_rate" IS 'rate used to convert amount to to_amount';
@@ -0,0 +1,15 @@
+ALTER TABLE "entries" ADD COLUMN "transfer_id" bigint;
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+CREATE INDEX ON "entries" ("account_id", "created_at");
+
+-- entries and the transfer of a TransferTx are created in the same db transaction, so they share now()
+UPDATE "entries" e SET "transfer_id" = t."id"
+FROM "transfers" t
+WHERE e."created_at" = t."created_at" AND (
+  (e."account_id" = t."from_account_id" AND e."amount" = -t."amount") OR
+  (e."account_id" = t."to_account_id" AND e."amount" = t."to_amount")
+);
+
+COMMENT ON COLUMN "entries"."transfer_id" IS 'transfer that created the entry, null for other entries';
@@ -0,0 +1,8 @@
+-- keyset pagination walks these indexes in (created_at, id) order
+-- entries use the ("account_id", "created_at") index of 000007, ties of created_at are few
+
+CREATE INDEX ON "accounts" ("owner", "created_at", "id");
+
+CREATE INDEX ON "transfers" ("from_account_id", "created_at", "id");
+
+CREATE INDEX ON "transfers" ("to_account_id", "created_at", "id");
@@ -0,0 +1,13 @@
+ALTER TABLE "accounts" ADD COLUMN "status" varchar NOT NULL DEFAULT 'active';
+
+ALTER TABLE "accounts" ADD CONSTRAINT "status_valid" CHECK ("status" IN ('active', 'frozen', 'closed'));
+
+ALTER TABLE "accounts" ADD COLUMN "status_reason" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "accounts" ADD COLUMN "status_changed_at" timestamptz NOT NULL DEFAULT (now());
+
+UPDATE "accounts" SET "status_changed_at" = "created_at";
+
+COMMENT ON COLUMN "accounts"."status" IS 'active, frozen or closed';
+
+COMMENT ON COLUMN "accounts"."status_reason" IS 'why the account was frozen, unfrozen or closed';
@@ -0,0 +1,31 @@
+CREATE TABLE "holds" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "captured_amount" bigint NOT NULL DEFAULT 0,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "transfer_id" bigint,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "amount_positive" CHECK ("amount" > 0),
+  CONSTRAINT "captured_amount_valid" CHECK ("captured_amount" >= 0 AND "captured_amount" <= "amount"),
+  CONSTRAINT "status_valid" CHECK ("status" IN ('pending', 'captured', 'voided'))
+);
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+-- only pending holds reduce the available balance
+CREATE INDEX ON "holds" ("account_id", "expires_at") WHERE "status" = 'pending';
+
+COMMENT ON COLUMN "holds"."account_id" IS 'account whose funds are reserved';
+
+COMMENT ON COLUMN "holds"."to_account_id" IS 'account that receives the funds when the hold is captured';
+
+COMMENT ON COLUMN "holds"."status" IS 'pending, captured or voided, a pending hold past expires_at is expired';
+
+COMMENT ON COLUMN "holds"."transfer_id" IS 'transfer created by the capture';
@@ -0,0 +1,18 @@
+CREATE TABLE "transfer_batches" (
+  "id" bigserial PRIMARY KEY,
+  "from_account_id" bigint NOT NULL,
+  "best_effort" boolean NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "transfer_batches" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "transfers" ADD COLUMN "batch_id" bigint;
+
+ALTER TABLE "transfers" ADD FOREIGN KEY ("batch_id") REFERENCES "transfer_batches" ("id");
+
+CREATE INDEX ON "transfers" ("batch_id");
+
+COMMENT ON COLUMN "transfer_batches"."best_effort" IS 'failed legs are skipped instead of rolling back the whole batch';
+
+COMMENT ON COLUMN "transfers"."batch_id" IS 'batch the transfer was made in, null for single transfers';
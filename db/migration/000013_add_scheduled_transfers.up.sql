@@ -0,0 +1,59 @@
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "frequency" varchar NOT NULL,
+  "repeat_interval" int NOT NULL DEFAULT 1,
+  "start_at" timestamptz NOT NULL,
+  "end_at" timestamptz,
+  "max_runs" int,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "next_run_at" timestamptz,
+  "run_count" int NOT NULL DEFAULT 0,
+  "failed_attempts" int NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "amount_positive" CHECK ("amount" > 0),
+  CONSTRAINT "frequency_valid" CHECK ("frequency" IN ('once', 'daily', 'weekly', 'monthly')),
+  CONSTRAINT "repeat_interval_positive" CHECK ("repeat_interval" > 0),
+  CONSTRAINT "status_valid" CHECK ("status" IN ('active', 'completed', 'cancelled'))
+);
+
+CREATE TABLE "scheduled_transfer_runs" (
+  "id" bigserial PRIMARY KEY,
+  "schedule_id" bigint NOT NULL,
+  "scheduled_for" timestamptz NOT NULL,
+  "attempt" int NOT NULL,
+  "transfer_id" bigint,
+  "error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("schedule_id") REFERENCES "scheduled_transfers" ("id");
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+CREATE INDEX ON "scheduled_transfers" ("from_account_id");
+
+-- the worker only looks for active schedules that are due
+CREATE INDEX ON "scheduled_transfers" ("next_run_at") WHERE "status" = 'active';
+
+CREATE INDEX ON "scheduled_transfer_runs" ("schedule_id");
+
+COMMENT ON COLUMN "scheduled_transfers"."repeat_interval" IS 'number of frequency units between two runs, e.g. 2 for every other week';
+
+COMMENT ON COLUMN "scheduled_transfers"."end_at" IS 'no run is scheduled after this time, null for no end';
+
+COMMENT ON COLUMN "scheduled_transfers"."max_runs" IS 'maximum number of runs, null for no limit';
+
+COMMENT ON COLUMN "scheduled_transfers"."next_run_at" IS 'time of the next run or retry, null when the schedule is not active';
+
+COMMENT ON COLUMN "scheduled_transfers"."run_count" IS 'number of occurrences done, including the ones skipped after failing';
+
+COMMENT ON COLUMN "scheduled_transfers"."failed_attempts" IS 'failed attempts of the current occurrence';
+
+COMMENT ON COLUMN "scheduled_transfer_runs"."transfer_id" IS 'transfer made by the run, null when it failed';
@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "fee_account_id";
+
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "fee";
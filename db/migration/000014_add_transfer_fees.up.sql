@@ -0,0 +1,11 @@
+ALTER TABLE "transfers" ADD COLUMN "fee" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "transfers" ADD COLUMN "fee_account_id" bigint;
+
+ALTER TABLE "transfers" ADD FOREIGN KEY ("fee_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_fee_check" CHECK ("fee" >= 0);
+
+COMMENT ON COLUMN "transfers"."fee" IS 'charged to the from account on top of amount, in its currency';
+
+COMMENT ON COLUMN "transfers"."fee_account_id" IS 'revenue account credited with the fee, null for transfers without a fee';
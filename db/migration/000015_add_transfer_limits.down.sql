@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "transfer_limits";
+
+ALTER TABLE IF EXISTS "users" DROP CONSTRAINT IF EXISTS "role_valid";
+
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "role";
@@ -0,0 +1,40 @@
+ALTER TABLE "users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'customer';
+
+ALTER TABLE "users" ADD CONSTRAINT "role_valid" CHECK ("role" IN ('customer', 'admin'));
+
+CREATE TABLE "transfer_limits" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint UNIQUE,
+  "username" varchar UNIQUE,
+  "daily_amount" bigint,
+  "monthly_amount" bigint,
+  "per_transaction_amount" bigint,
+  "hourly_count" integer,
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "scope_valid" CHECK ("account_id" IS NULL OR "username" IS NULL),
+  -- null limits pass the check, they are inherited
+  CONSTRAINT "limits_positive" CHECK (
+    "daily_amount" > 0 AND "monthly_amount" > 0 AND "per_transaction_amount" > 0 AND "hourly_count" > 0
+  )
+);
+
+ALTER TABLE "transfer_limits" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "transfer_limits" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+-- the system default row has neither an account nor a user, there is exactly one of it
+CREATE UNIQUE INDEX "transfer_limits_default_key" ON "transfer_limits" ((true)) WHERE "account_id" IS NULL AND "username" IS NULL;
+
+INSERT INTO "transfer_limits" DEFAULT VALUES;
+
+COMMENT ON COLUMN "users"."role" IS 'customer or admin, admins are promoted in the db';
+
+COMMENT ON TABLE "transfer_limits" IS 'limits of an account, defaults of the accounts of a user, or the system defaults when both are null';
+
+COMMENT ON COLUMN "transfer_limits"."daily_amount" IS 'outgoing amount in the last 24 hours, null to inherit the limit';
+
+COMMENT ON COLUMN "transfer_limits"."monthly_amount" IS 'outgoing amount in the last 30 days, null to inherit the limit';
+
+COMMENT ON COLUMN "transfer_limits"."per_transaction_amount" IS 'maximum amount of a single transfer, null to inherit the limit';
+
+COMMENT ON COLUMN "transfer_limits"."hourly_count" IS 'number of outgoing transfers in the last hour, null to inherit the limit';
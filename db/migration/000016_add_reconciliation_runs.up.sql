@@ -0,0 +1,16 @@
+CREATE TABLE "reconciliation_runs" (
+  "id" bigserial PRIMARY KEY,
+  "started_at" timestamptz NOT NULL,
+  "finished_at" timestamptz NOT NULL,
+  "accounts_checked" bigint NOT NULL,
+  "transfers_checked" bigint NOT NULL,
+  "discrepancies" bigint NOT NULL,
+  "findings" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "reconciliation_runs" ("started_at");
+
+COMMENT ON COLUMN "reconciliation_runs"."discrepancies" IS 'drifting accounts, orphan entries and unbalanced transfers found by the run';
+
+COMMENT ON COLUMN "reconciliation_runs"."findings" IS 'the report of the run, as printed by the reconcile command';
@@ -0,0 +1,20 @@
+CREATE TABLE "journal_entries" (
+  "id" bigserial PRIMARY KEY,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "entries" ADD COLUMN "journal_id" bigint;
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("journal_id") REFERENCES "journal_entries" ("id");
+
+CREATE INDEX ON "entries" ("journal_id");
+
+-- every transfer so far posted its entries in one go, so each of them becomes a journal with the id of the transfer
+INSERT INTO "journal_entries" ("id", "created_at")
+SELECT "id", "created_at" FROM "transfers";
+
+UPDATE "entries" SET "journal_id" = "transfer_id" WHERE "transfer_id" IS NOT NULL;
+
+SELECT setval(pg_get_serial_sequence('journal_entries', 'id'), COALESCE(MAX("id"), 0) + 1, false) FROM "journal_entries";
+
+COMMENT ON COLUMN "entries"."journal_id" IS 'journal that posted the entry with the other legs, null for entries without a transfer made before journals';
@@ -0,0 +1,3 @@
+ALTER TABLE "accounts" ADD COLUMN "status_changed_by" varchar NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN "accounts"."status_changed_by" IS 'username of the user who froze, unfroze or closed the account, empty before the first change';
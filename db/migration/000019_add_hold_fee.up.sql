@@ -0,0 +1,5 @@
+ALTER TABLE "holds" ADD COLUMN "fee" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "holds" ADD CONSTRAINT "holds_fee_check" CHECK ("fee" >= 0);
+
+COMMENT ON COLUMN "holds"."fee" IS 'fee of the held amount, reserved on top of it since the capture charges it';
@@ -18,9 +18,18 @@ FOR NO KEY UPDATE;
 
 -- name: ListAccounts :many
 SELECT * FROM accounts
+WHERE owner = $1
 ORDER BY id
-LIMIT $1
-OFFSET $2;
+LIMIT $2
+OFFSET $3;
+
+-- name: ListAccountsAfter :many
+-- keyset pagination, the next page starts after the last account of the previous one
+SELECT * FROM accounts
+WHERE owner = sqlc.arg(owner) AND
+    (created_at, id) > (sqlc.arg(after_created_at)::timestamptz, sqlc.arg(after_id)::bigint)
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
 
 -- name: UpdateAccount :one
 UPDATE accounts 
@@ -34,7 +43,14 @@ SET balance = balance + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
 RETURNING *;
 
+-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $2
+WHERE id = $1
+RETURNING *;
 
--- name: DeleteAccount :exec
-DELETE FROM accounts
-WHERE id = $1;
+-- name: UpdateAccountStatus :one
+UPDATE accounts
+SET status = $2, status_reason = $3, status_changed_at = now(), status_changed_by = $4
+WHERE id = $1
+RETURNING *;
@@ -1,19 +1,49 @@
-
 -- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id,
+  journal_id
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4
 ) RETURNING *;
 
 -- name: GetEntry :one
 SELECT * FROM entries
 WHERE id = $1 LIMIT 1;
 
+-- name: ListJournalEntries :many
+-- legs of a journal, in the order they were posted
+SELECT * FROM entries
+WHERE journal_id = $1
+ORDER BY id;
+
 -- name: ListEntries :many
 SELECT * FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2
-OFFSET $3;
\ No newline at end of file
+OFFSET $3;
+
+-- name: ListEntriesAfter :many
+-- keyset pagination, the next page starts after the last entry of the previous one
+SELECT * FROM entries
+WHERE account_id = sqlc.arg(account_id) AND
+    (created_at, id) > (sqlc.arg(after_created_at)::timestamptz, sqlc.arg(after_id)::bigint)
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: SumEntriesSince :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS total FROM entries
+WHERE account_id = $1 AND created_at >= $2;
+
+-- name: ListStatementEntries :many
+SELECT e.id, e.account_id, e.amount, e.created_at, e.transfer_id, t.from_account_id, t.to_account_id, t.fee_account_id,
+    -- fee legs are posted after the principal legs, so the fee is the last entry of the account in the transfer
+    COALESCE(t.fee > 0 AND e.account_id IN (t.from_account_id, t.fee_account_id) AND e.id = (
+        SELECT MAX(f.id) FROM entries f WHERE f.transfer_id = e.transfer_id AND f.account_id = e.account_id
+    ), false)::bool AS is_fee
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+WHERE e.account_id = sqlc.arg(account_id) AND e.created_at >= sqlc.arg(from_time) AND e.created_at < sqlc.arg(to_time)
+ORDER BY e.created_at, e.id;
@@ -0,0 +1,41 @@
+-- name: CreateHold :one
+INSERT INTO holds (
+  account_id,
+  to_account_id,
+  amount,
+  fee,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetHold :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: CaptureHold :one
+UPDATE holds
+SET status = 'captured', captured_amount = $2, transfer_id = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: VoidHold :one
+UPDATE holds
+SET status = 'voided'
+WHERE id = $1
+RETURNING *;
+
+-- name: GetHeldAmount :one
+-- funds reserved by the pending holds of the account, expired holds dont reserve anything
+SELECT COALESCE(SUM(amount + fee), 0)::bigint AS held_amount FROM holds
+WHERE account_id = $1 AND status = 'pending' AND expires_at > now();
+
+-- name: ListHeldAmounts :many
+SELECT account_id, SUM(amount + fee)::bigint AS held_amount FROM holds
+WHERE account_id = ANY(sqlc.arg(account_ids)::bigint[]) AND status = 'pending' AND expires_at > now()
+GROUP BY account_id;
@@ -0,0 +1,23 @@
+-- name: CreateIdempotencyKey :exec
+INSERT INTO idempotency_keys (
+  username,
+  idempotency_key,
+  request_path,
+  request_hash
+) VALUES (
+  $1, $2, $3, $4
+) ON CONFLICT (username, idempotency_key) DO NOTHING;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE username = $1 AND idempotency_key = $2 LIMIT 1;
+
+-- name: GetIdempotencyKeyForUpdate :one
+SELECT * FROM idempotency_keys
+WHERE username = $1 AND idempotency_key = $2 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: SetIdempotencyKeyResponse :exec
+UPDATE idempotency_keys
+SET response_body = $3
+WHERE username = $1 AND idempotency_key = $2;
@@ -0,0 +1,7 @@
+-- name: CreateJournalEntry :one
+INSERT INTO journal_entries DEFAULT VALUES
+RETURNING *;
+
+-- name: GetJournalEntry :one
+SELECT * FROM journal_entries
+WHERE id = $1 LIMIT 1;
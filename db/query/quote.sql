@@ -0,0 +1,15 @@
+-- name: CreateQuote :one
+INSERT INTO quotes (
+  id,
+  username,
+  from_currency,
+  to_currency,
+  rate,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetQuote :one
+SELECT * FROM quotes
+WHERE id = $1 LIMIT 1;
@@ -0,0 +1,91 @@
+-- name: GetLedgerCounts :one
+SELECT
+  (SELECT COUNT(*) FROM accounts) AS accounts,
+  (SELECT COUNT(*) FROM transfers) AS transfers;
+
+-- name: ListBalanceDrifts :many
+-- accounts whose balance is not the sum of their entries
+SELECT
+  a.id AS account_id,
+  a.balance,
+  COALESCE(SUM(e.amount), 0)::bigint AS entries_total,
+  (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS drift
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;
+
+-- name: ListOrphanEntries :many
+-- entries that werent posted by a transfer or a journal
+SELECT * FROM entries
+WHERE transfer_id IS NULL AND journal_id IS NULL
+ORDER BY id;
+
+-- name: ListUnbalancedJournals :many
+-- journals whose legs dont add up to zero in a currency
+-- the legs of a cross currency transfer dont, so the journals of a single transfer are checked by ListUnbalancedTransfers instead
+WITH transfer_journals AS (
+  SELECT journal_id FROM entries
+  WHERE journal_id IS NOT NULL
+  GROUP BY journal_id
+  HAVING COUNT(*) = COUNT(transfer_id) AND COUNT(DISTINCT transfer_id) = 1
+)
+SELECT
+  e.journal_id::bigint AS journal_id,
+  a.currency,
+  SUM(e.amount)::bigint AS total
+FROM entries e
+JOIN accounts a ON a.id = e.account_id
+WHERE e.journal_id IS NOT NULL AND e.journal_id NOT IN (SELECT journal_id FROM transfer_journals)
+GROUP BY e.journal_id, a.currency
+HAVING SUM(e.amount) <> 0
+ORDER BY 1, 2;
+
+-- name: ListUnbalancedTransfers :many
+-- transfers whose entries dont match them, account by account
+-- a transfer debits amount + fee from the sender, credits to_amount to the receiver and the fee to the fee account
+WITH expected AS (
+  SELECT id AS transfer_id, from_account_id AS account_id, -amount AS amount FROM transfers
+  UNION ALL
+  SELECT id, to_account_id, to_amount FROM transfers
+  UNION ALL
+  SELECT id, from_account_id, -fee FROM transfers WHERE fee > 0
+  UNION ALL
+  SELECT id, fee_account_id, fee FROM transfers WHERE fee > 0
+), expected_totals AS (
+  SELECT transfer_id, account_id, SUM(amount) AS amount, COUNT(*) AS entries
+  FROM expected
+  GROUP BY transfer_id, account_id
+), actual_totals AS (
+  SELECT transfer_id, account_id, SUM(amount) AS amount, COUNT(*) AS entries
+  FROM entries
+  WHERE transfer_id IS NOT NULL
+  GROUP BY transfer_id, account_id
+)
+SELECT
+  COALESCE(x.transfer_id, a.transfer_id)::bigint AS transfer_id,
+  COALESCE(SUM(x.entries), 0)::bigint AS expected_entries,
+  COALESCE(SUM(a.entries), 0)::bigint AS actual_entries
+FROM expected_totals x
+FULL JOIN actual_totals a ON a.transfer_id = x.transfer_id AND a.account_id = x.account_id
+GROUP BY COALESCE(x.transfer_id, a.transfer_id)
+HAVING bool_or(x.amount IS DISTINCT FROM a.amount OR x.entries IS DISTINCT FROM a.entries)
+ORDER BY 1;
+
+-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs (
+  started_at,
+  finished_at,
+  accounts_checked,
+  transfers_checked,
+  discrepancies,
+  findings
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetLatestReconciliationRun :one
+SELECT * FROM reconciliation_runs
+ORDER BY started_at DESC
+LIMIT 1;
@@ -0,0 +1,66 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  frequency,
+  repeat_interval,
+  start_at,
+  end_at,
+  max_runs,
+  next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: ListScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE from_account_id = $1
+ORDER BY id;
+
+-- name: UpdateScheduledTransfer :one
+-- only active schedules can be changed, completed and cancelled ones are kept as they are
+UPDATE scheduled_transfers
+SET amount = $2, end_at = $3, max_runs = $4
+WHERE id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: CancelScheduledTransfer :one
+UPDATE scheduled_transfers
+SET status = 'cancelled', next_run_at = NULL
+WHERE id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: ClaimDueScheduledTransfer :one
+-- schedules locked by another worker are skipped, so many workers can run side by side
+SELECT * FROM scheduled_transfers
+WHERE status = 'active' AND next_run_at <= sqlc.arg(now)
+ORDER BY next_run_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: AdvanceScheduledTransfer :one
+UPDATE scheduled_transfers
+SET status = $2, next_run_at = $3, run_count = $4, failed_attempts = $5
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateScheduledTransferRun :one
+INSERT INTO scheduled_transfer_runs (
+  schedule_id,
+  scheduled_for,
+  attempt,
+  transfer_id,
+  error
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: ListScheduledTransferRuns :many
+SELECT * FROM scheduled_transfer_runs
+WHERE schedule_id = $1
+ORDER BY id;
@@ -2,20 +2,85 @@
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  to_amount,
+  exchange_rate,
+  reversal_of,
+  batch_id,
+  fee,
+  fee_account_id
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
 ) RETURNING *;
 
 -- name: GetTransfer :one
 SELECT * FROM transfers
 WHERE id = $1 LIMIT 1;
 
+-- name: GetTransferForUpdate :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: AddTransferReversedAmount :one
+UPDATE transfers
+SET reversed_amount = reversed_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ListTransferReversals :many
+SELECT * FROM transfers
+WHERE reversal_of = $1
+ORDER BY id;
+
 -- name: ListTransfers :many
 SELECT * FROM transfers
-WHERE 
+WHERE
     from_account_id = $1 OR
-    to_account_id = $2
+    to_account_id = $1
 ORDER BY id
-LIMIT $3
-OFFSET $4;
+LIMIT $2
+OFFSET $3;
+
+-- name: ListAccountTransfers :many
+-- amounts are compared in the currency of the account, so incoming transfers use to_amount
+-- newest first, the next page starts before the last transfer of the previous one
+SELECT * FROM transfers
+WHERE
+    ((sqlc.arg(outgoing)::boolean AND from_account_id = sqlc.arg(account_id)) OR
+     (sqlc.arg(incoming)::boolean AND to_account_id = sqlc.arg(account_id))) AND
+    created_at >= sqlc.arg(from_time) AND
+    created_at < sqlc.arg(to_time) AND
+    (CASE WHEN from_account_id = sqlc.arg(account_id) THEN amount ELSE to_amount END)
+        BETWEEN sqlc.arg(min_amount)::bigint AND sqlc.arg(max_amount)::bigint AND
+    (sqlc.arg(counterparty_id)::bigint = 0 OR
+     from_account_id = sqlc.arg(counterparty_id) OR
+     to_account_id = sqlc.arg(counterparty_id)) AND
+    (created_at, id) < (sqlc.arg(before_created_at)::timestamptz, sqlc.arg(before_id)::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetOutgoingTransferTotals :one
+-- totals of the rolling windows of the limits, ending now
+-- reversals are refunds, they dont count against the limits of the sender
+SELECT
+  COALESCE(SUM(amount) FILTER (WHERE created_at >= now() - interval '24 hours'), 0)::bigint AS daily_amount,
+  COALESCE(SUM(amount), 0)::bigint AS monthly_amount,
+  COUNT(*) FILTER (WHERE created_at >= now() - interval '1 hour') AS hourly_count
+FROM transfers
+WHERE from_account_id = $1 AND reversal_of IS NULL AND created_at >= now() - interval '30 days';
+
+-- name: ListOwnerOutgoingTransferTotals :many
+-- totals of the user limits, one row for each currency of the accounts of the owner
+-- amounts are in the currency of the accounts, they are converted before they are summed
+-- reversals are refunds, they dont count against the limits of the sender
+SELECT
+  a.currency,
+  COALESCE(SUM(t.amount) FILTER (WHERE t.created_at >= now() - interval '24 hours'), 0)::bigint AS daily_amount,
+  COALESCE(SUM(t.amount), 0)::bigint AS monthly_amount,
+  COUNT(*) FILTER (WHERE t.created_at >= now() - interval '1 hour') AS hourly_count
+FROM transfers t
+JOIN accounts a ON a.id = t.from_account_id
+WHERE a.owner = $1 AND t.reversal_of IS NULL AND t.created_at >= now() - interval '30 days'
+GROUP BY a.currency
+ORDER BY a.currency;
@@ -0,0 +1,16 @@
+-- name: CreateTransferBatch :one
+INSERT INTO transfer_batches (
+  from_account_id,
+  best_effort
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: GetTransferBatch :one
+SELECT * FROM transfer_batches
+WHERE id = $1 LIMIT 1;
+
+-- name: ListBatchTransfers :many
+SELECT * FROM transfers
+WHERE batch_id = $1
+ORDER BY id;
@@ -0,0 +1,57 @@
+-- name: GetDefaultTransferLimit :one
+SELECT * FROM transfer_limits
+WHERE account_id IS NULL AND username IS NULL
+LIMIT 1;
+
+-- name: GetAccountTransferLimit :one
+SELECT * FROM transfer_limits
+WHERE account_id = $1 LIMIT 1;
+
+-- name: GetUserTransferLimit :one
+SELECT * FROM transfer_limits
+WHERE username = $1 LIMIT 1;
+
+-- name: ListApplicableTransferLimits :many
+-- limits of the account, the ones of its owner and the system defaults, see EffectiveTransferLimits
+SELECT * FROM transfer_limits
+WHERE account_id = sqlc.arg(account_id) OR username = sqlc.arg(owner) OR (account_id IS NULL AND username IS NULL);
+
+-- name: UpdateDefaultTransferLimit :one
+UPDATE transfer_limits
+SET daily_amount = $1, monthly_amount = $2, per_transaction_amount = $3, hourly_count = $4, updated_at = now()
+WHERE account_id IS NULL AND username IS NULL
+RETURNING *;
+
+-- name: UpsertAccountTransferLimit :one
+INSERT INTO transfer_limits (
+  account_id,
+  daily_amount,
+  monthly_amount,
+  per_transaction_amount,
+  hourly_count
+) VALUES (
+  $1, $2, $3, $4, $5
+) ON CONFLICT (account_id) DO UPDATE
+SET daily_amount = EXCLUDED.daily_amount,
+  monthly_amount = EXCLUDED.monthly_amount,
+  per_transaction_amount = EXCLUDED.per_transaction_amount,
+  hourly_count = EXCLUDED.hourly_count,
+  updated_at = now()
+RETURNING *;
+
+-- name: UpsertUserTransferLimit :one
+INSERT INTO transfer_limits (
+  username,
+  daily_amount,
+  monthly_amount,
+  per_transaction_amount,
+  hourly_count
+) VALUES (
+  $1, $2, $3, $4, $5
+) ON CONFLICT (username) DO UPDATE
+SET daily_amount = EXCLUDED.daily_amount,
+  monthly_amount = EXCLUDED.monthly_amount,
+  per_transaction_amount = EXCLUDED.per_transaction_amount,
+  hourly_count = EXCLUDED.hourly_count,
+  updated_at = now()
+RETURNING *;
@@ -10,4 +10,10 @@ INSERT INTO users (
 
 -- name: GetUser :one
 SELECT * FROM users
-WHERE username= $1 LIMIT 1;
\ No newline at end of file
+WHERE username= $1 LIMIT 1;
+
+-- name: GetUserForUpdate :one
+-- locks the user, so the transfers of its accounts are checked against its limits one at a time
+SELECT * FROM users
+WHERE username = $1 LIMIT 1
+FOR NO KEY UPDATE;
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Statuses of an account
+// frozen accounts can be unfrozen later, closed accounts stay closed but can still be queried
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen"
+	AccountStatusClosed = "closed"
+)
+
+// Different types of error returned by ChangeAccountStatusTx
+var (
+	// ErrInvalidStatusTransition is returned e.g. when a closed account is frozen or an active account is unfrozen
+	ErrInvalidStatusTransition = errors.New("invalid account status transition")
+	// ErrBalanceNotZero is returned when an account with money in it is closed
+	ErrBalanceNotZero = errors.New("account balance is not zero")
+	// ErrFrozenByAnotherUser is returned when an account is unfrozen by someone else than the user who froze it
+	ErrFrozenByAnotherUser = errors.New("account was frozen by another user")
+)
+
+// allowed status changes, the key is the current status
+var accountStatusTransitions = map[string][]string{
+	AccountStatusActive: {AccountStatusFrozen, AccountStatusClosed},
+	AccountStatusFrozen: {AccountStatusActive, AccountStatusClosed},
+}
+
+// ChangeAccountStatusTxParams contains the input parameters of the account status transaction
+type ChangeAccountStatusTxParams struct {
+	AccountID int64  `json:"account_id"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason"`
+	// username of the user changing the status
+	ChangedBy string `json:"changed_by"`
+	// admins can unfreeze the accounts frozen by anyone
+	ByAdmin bool `json:"by_admin"`
+}
+
+// ChangeAccountStatusTx freezes, unfreezes or closes an account
+// the account row is locked, so a concurrent transfer cannot change the balance before the account is closed
+func (store *SQLStore) ChangeAccountStatusTx(ctx context.Context, arg ChangeAccountStatusTxParams) (Account, error) {
+	var account Account
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		current, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if !canChangeAccountStatus(current.Status, arg.Status) {
+			return fmt.Errorf("%w: account [%d] is %s, it cannot be %s",
+				ErrInvalidStatusTransition, arg.AccountID, current.Status, arg.Status)
+		}
+
+		// an owner cannot undo the freeze of an admin, the status is checked in the tx since the account row is locked
+		if current.Status == AccountStatusFrozen && arg.Status == AccountStatusActive && !arg.ByAdmin &&
+			len(current.StatusChangedBy) > 0 && current.StatusChangedBy != arg.ChangedBy {
+			return fmt.Errorf("account [%d]: %w", arg.AccountID, ErrFrozenByAnotherUser)
+		}
+
+		if arg.Status == AccountStatusClosed && current.Balance != 0 {
+			return fmt.Errorf("account [%d] has a balance of %d: %w", arg.AccountID, current.Balance, ErrBalanceNotZero)
+		}
+
+		account, err = q.UpdateAccountStatus(ctx, UpdateAccountStatusParams{
+			ID:              arg.AccountID,
+			Status:          arg.Status,
+			StatusReason:    arg.Reason,
+			StatusChangedBy: arg.ChangedBy,
+		})
+		return err
+	})
+
+	return account, err
+}
+
+func canChangeAccountStatus(from string, to string) bool {
+	for _, status := range accountStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccountActive returns the error of a transfer involving a frozen or closed account
+func checkAccountActive(account Account) error {
+	switch account.Status {
+	case AccountStatusFrozen:
+		return fmt.Errorf("account [%d]: %w", account.ID, ErrAccountFrozen)
+	case AccountStatusClosed:
+		return fmt.Errorf("account [%d]: %w", account.ID, ErrAccountClosed)
+	}
+	return nil
+}
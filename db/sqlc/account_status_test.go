@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeAccountStatusTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account := fundAccount(t, createRandomAccount(t), 0)
+	require.Equal(t, AccountStatusActive, account.Status)
+
+	frozen, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusFrozen,
+		Reason:    "lost card",
+		ChangedBy: account.Owner,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusFrozen, frozen.Status)
+	require.Equal(t, "lost card", frozen.StatusReason)
+	require.Equal(t, account.Owner, frozen.StatusChangedBy)
+	require.True(t, frozen.StatusChangedAt.After(account.StatusChangedAt))
+
+	// already frozen
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusFrozen,
+	})
+	require.True(t, errors.Is(err, ErrInvalidStatusTransition))
+
+	closed, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusClosed,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, closed.Status)
+
+	// closed accounts stay closed, but can still be queried
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusActive,
+	})
+	require.True(t, errors.Is(err, ErrInvalidStatusTransition))
+
+	queried, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, queried.Status)
+}
+
+func TestUnfreezeAccountFrozenByAdmin(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account := createRandomAccount(t)
+	admin := createRandomUser(t)
+
+	_, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusFrozen,
+		ChangedBy: admin.Username,
+		ByAdmin:   true,
+	})
+	require.NoError(t, err)
+
+	// the owner cannot undo the freeze of an admin
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusActive,
+		ChangedBy: account.Owner,
+	})
+	require.True(t, errors.Is(err, ErrFrozenByAnotherUser))
+
+	// any admin can
+	active, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusActive,
+		ChangedBy: createRandomUser(t).Username,
+		ByAdmin:   true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusActive, active.Status)
+}
+
+func TestCloseAccountWithBalance(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account := fundAccount(t, createRandomAccount(t), 10)
+
+	_, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusClosed,
+	})
+	require.True(t, errors.Is(err, ErrBalanceNotZero))
+}
+
+func TestTransferTxInactiveAccount(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccount(t), 1000)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	_, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account2.ID,
+		Status:    AccountStatusFrozen,
+	})
+	require.NoError(t, err)
+
+	// frozen accounts can neither receive nor send money
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.True(t, errors.Is(err, ErrAccountFrozen))
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        10,
+	})
+	require.True(t, errors.Is(err, ErrAccountFrozen))
+
+	// the tx is rolled back
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+
+	account3 := fundAccount(t, createRandomAccountWithCurrency(t, account1.Currency), 0)
+	_, err = store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account3.ID,
+		Status:    AccountStatusClosed,
+	})
+	require.NoError(t, err)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account3.ID,
+		Amount:        10,
+	})
+	require.True(t, errors.Is(err, ErrAccountClosed))
+}
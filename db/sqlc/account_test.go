@@ -2,7 +2,6 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"testing"
 	"time"
 
@@ -29,6 +28,7 @@ func createRandomAccount(t *testing.T) Account {
 	require.Equal(t, arg.Owner, account.Owner)
 	require.Equal(t, arg.Balance, account.Balance)
 	require.Equal(t, arg.Currency, account.Currency)
+	require.Equal(t, AccountStatusActive, account.Status)
 
 	require.NotZero(t, account.ID)
 	require.NotZero(t, account.CreatedAt)
@@ -78,34 +78,60 @@ func TestUpdateAccount(t *testing.T) {
 
 }
 
-func TestDeleteAccount(t *testing.T) {
-	account1 := createRandomAccount(t)
-	err := testQueries.DeleteAccount(context.Background(), account1.ID)
-	require.NoError(t, err)
-
-	account2, err := testQueries.GetAccount(context.Background(), account1.ID)
-	require.Error(t, err)
-	require.EqualError(t, err, sql.ErrNoRows.Error())
-	require.Empty(t, account2)
-}
-
 func TestListAccounts(t *testing.T) {
+	var lastAccount Account
 	for i := 0; i < 10; i++ {
-		createRandomAccount(t)
+		lastAccount = createRandomAccount(t)
 	}
 
-	// skip the first five and return the last five
+	// each random account has its own owner, so only the owner's accounts are returned
 	arg := ListAccountsParams{
+		Owner:  lastAccount.Owner,
 		Limit:  5,
-		Offset: 5,
+		Offset: 0,
 	}
 
 	accounts, err := testQueries.ListAccounts(context.Background(), arg)
 	require.NoError(t, err)
-	require.Len(t, accounts, 5)
+	require.NotEmpty(t, accounts)
 
 	for _, account := range accounts {
 		require.NotEmpty(t, account)
+		require.Equal(t, lastAccount.Owner, account.Owner)
+	}
+
+}
+
+func TestListAccountsAfter(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account1.Owner,
+		Balance:  util.RandomMoney(),
+		Currency: util.RandomCurrency(),
+	})
+	require.NoError(t, err)
+
+	arg := ListAccountsAfterParams{
+		Owner: account1.Owner,
+		Limit: 1,
 	}
 
+	accounts, err := testQueries.ListAccountsAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, account1.ID, accounts[0].ID)
+
+	// next page starts after the last account of the previous one
+	arg.AfterCreatedAt = accounts[0].CreatedAt
+	arg.AfterID = accounts[0].ID
+	accounts, err = testQueries.ListAccountsAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	require.Equal(t, account2.ID, accounts[0].ID)
+
+	arg.AfterCreatedAt = accounts[0].CreatedAt
+	arg.AfterID = accounts[0].ID
+	accounts, err = testQueries.ListAccountsAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, accounts)
 }
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/keremakillioglu/simplebank/fx"
+)
+
+// errSavepoint is returned when a savepoint cannot be created, released or rolled back to
+// the tx cannot be used anymore in that case
+var errSavepoint = errors.New("savepoint failed")
+
+// BatchTransferLeg is a single payment of a batch
+// amount is in the currency of the from account, every to account must have the same currency
+type BatchTransferLeg struct {
+	ToAccountID int64 `json:"to_account_id"`
+	Amount      int64 `json:"amount"`
+}
+
+// BatchTransferTxParams contains the input parameters of the batch transfer transaction
+type BatchTransferTxParams struct {
+	FromAccountID int64              `json:"from_account_id"`
+	Legs          []BatchTransferLeg `json:"legs"`
+	// BestEffort skips the failed legs, otherwise the first failed leg rolls back the whole batch
+	BestEffort bool `json:"best_effort"`
+}
+
+// BatchTransferLegResult is the result of a single leg, either Transfer or Err is set
+type BatchTransferLegResult struct {
+	Transfer *TransferTxResult `json:"transfer,omitempty"`
+	Err      error             `json:"-"`
+}
+
+// BatchTransferTxResult is the result of the batch transfer transaction
+// legs are in the same order as in the params
+type BatchTransferTxResult struct {
+	Batch TransferBatch            `json:"batch"`
+	Legs  []BatchTransferLegResult `json:"legs"`
+}
+
+// BatchTransferTx pays many accounts from one account within a single db transaction
+// all accounts are locked in the order of their ids before any leg is posted, so concurrent batches and transfers cannot deadlock
+func (store *SQLStore) BatchTransferTx(ctx context.Context, arg BatchTransferTxParams) (BatchTransferTxResult, error) {
+	var result BatchTransferTxResult
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		// reset the result of a previous attempt, the tx might be retried
+		result = BatchTransferTxResult{Legs: make([]BatchTransferLegResult, len(arg.Legs))}
+
+		accounts, err := lockBatchAccounts(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+
+		result.Batch, err = q.CreateTransferBatch(ctx, CreateTransferBatchParams{
+			FromAccountID: arg.FromAccountID,
+			BestEffort:    arg.BestEffort,
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, leg := range arg.Legs {
+			transfer, err := postBatchLeg(ctx, q, store.fees, store.rates, result.Batch, accounts, leg, arg.BestEffort)
+			if err != nil {
+				// retrying the whole tx is the only way out of a serialization failure or a deadlock
+				if !arg.BestEffort || isRetryableError(err) || errors.Is(err, errSavepoint) {
+					return fmt.Errorf("leg [%d]: %w", i, err)
+				}
+				result.Legs[i].Err = err
+				continue
+			}
+			result.Legs[i].Transfer = &transfer
+		}
+
+		return nil
+	})
+	if err == nil {
+		for _, leg := range result.Legs {
+			if leg.Transfer != nil {
+				countTransfer(*leg.Transfer)
+			}
+		}
+	}
+
+	return result, err
+}
+
+// lockBatchAccounts locks the from account and all to accounts in the order of their ids
+// to accounts that cannot be found are left out, their legs fail later on
+func lockBatchAccounts(ctx context.Context, q *Queries, arg BatchTransferTxParams) (map[int64]Account, error) {
+	ids := []int64{arg.FromAccountID}
+	for _, leg := range arg.Legs {
+		ids = append(ids, leg.ToAccountID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	accounts := make(map[int64]Account, len(ids))
+	for _, id := range ids {
+		if _, ok := accounts[id]; ok {
+			continue
+		}
+
+		account, err := q.GetAccountForUpdate(ctx, id)
+		if err == sql.ErrNoRows && id != arg.FromAccountID {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("account [%d]: %w", id, err)
+		}
+		accounts[id] = account
+	}
+
+	return accounts, nil
+}
+
+// postBatchLeg posts a single leg of the batch
+// legs of a best effort batch run in a savepoint, so a failed leg rolls back only its own queries
+func postBatchLeg(ctx context.Context, q *Queries, fees FeeQuoter, rates fx.RateProvider, batch TransferBatch, accounts map[int64]Account, leg BatchTransferLeg, bestEffort bool) (TransferTxResult, error) {
+	from := accounts[batch.FromAccountID]
+	to, ok := accounts[leg.ToAccountID]
+	if !ok {
+		return TransferTxResult{}, fmt.Errorf("account [%d]: %w", leg.ToAccountID, sql.ErrNoRows)
+	}
+	if to.Currency != from.Currency {
+		return TransferTxResult{}, fmt.Errorf("%w: account [%d] is in %s, not %s", ErrCurrencyMismatch, to.ID, to.Currency, from.Currency)
+	}
+
+	arg := CreateTransferParams{
+		FromAccountID: batch.FromAccountID,
+		ToAccountID:   leg.ToAccountID,
+		Amount:        leg.Amount,
+		ToAmount:      leg.Amount,
+		ExchangeRate:  "1",
+		BatchID:       sql.NullInt64{Int64: batch.ID, Valid: true},
+	}
+
+	// every leg counts against the limits of the from account and is charged a fee, like a single transfer
+	post := func() (TransferTxResult, error) {
+		result, err := postTransfer(ctx, q, fees, arg, 0)
+		if err != nil {
+			return result, err
+		}
+		return result, checkTransferLimits(ctx, q, rates, result.FromAccount, leg.Amount)
+	}
+
+	if !bestEffort {
+		return post()
+	}
+
+	var result TransferTxResult
+	err := savepoint(ctx, q, "batch_leg", func() error {
+		var err error
+		result, err = post()
+		return err
+	})
+	return result, err
+}
+
+// savepoint runs fn inside of a savepoint of the current tx
+// when fn fails, the tx is rolled back to the savepoint and can still be committed
+// errors of the savepoint itself wrap errSavepoint
+func savepoint(ctx context.Context, q *Queries, name string, fn func() error) error {
+	if _, err := q.db.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("%w: %v", errSavepoint, err)
+	}
+
+	if err := fn(); err != nil {
+		if _, rbErr := q.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w: %v, rb err: %v", errSavepoint, err, rbErr)
+		}
+		return err
+	}
+
+	if _, err := q.db.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("%w: %v", errSavepoint, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTransferTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	account3 := createRandomAccountWithCurrency(t, util.USD)
+
+	result, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: account1.ID,
+		Legs: []BatchTransferLeg{
+			{ToAccountID: account2.ID, Amount: 10},
+			{ToAccountID: account3.ID, Amount: 20},
+			{ToAccountID: account2.ID, Amount: 30},
+		},
+	})
+	require.NoError(t, err)
+	require.NotZero(t, result.Batch.ID)
+	require.Len(t, result.Legs, 3)
+
+	for _, leg := range result.Legs {
+		require.NoError(t, leg.Err)
+		require.Equal(t, result.Batch.ID, leg.Transfer.Transfer.BatchID.Int64)
+	}
+	require.Equal(t, int64(40), result.Legs[2].Transfer.FromAccount.Balance)
+	require.Equal(t, account2.Balance+40, result.Legs[2].Transfer.ToAccount.Balance)
+
+	transfers, err := testQueries.ListBatchTransfers(context.Background(), sql.NullInt64{Int64: result.Batch.ID, Valid: true})
+	require.NoError(t, err)
+	require.Len(t, transfers, 3)
+}
+
+func TestBatchTransferTxWithFee(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	account3 := createRandomAccountWithCurrency(t, util.USD)
+	feeAccount := createRandomAccountWithCurrency(t, util.USD)
+	store := newFeeStore(t, feeAccount, 5)
+
+	// every leg is charged the fee, like a single transfer
+	result, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: account1.ID,
+		Legs: []BatchTransferLeg{
+			{ToAccountID: account2.ID, Amount: 10},
+			{ToAccountID: account3.ID, Amount: 20},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Legs, 2)
+
+	for _, leg := range result.Legs {
+		require.NoError(t, leg.Err)
+		require.Equal(t, int64(5), leg.Transfer.Transfer.Fee)
+		require.Equal(t, feeAccount.ID, leg.Transfer.FeeEntry.AccountID)
+	}
+	require.Equal(t, int64(60), result.Legs[1].Transfer.FromAccount.Balance)
+
+	updatedFeeAccount, err := testQueries.GetAccount(context.Background(), feeAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, feeAccount.Balance+10, updatedFeeAccount.Balance)
+
+	// the fee of the last leg is not covered, so the whole batch is rolled back
+	_, err = store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: account1.ID,
+		Legs: []BatchTransferLeg{
+			{ToAccountID: account2.ID, Amount: 30},
+			{ToAccountID: account3.ID, Amount: 20},
+		},
+	})
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+}
+
+func TestBatchTransferTxAllOrNothing(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	// second leg overdraws the account, so the first one is rolled back as well
+	_, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: account1.ID,
+		Legs: []BatchTransferLeg{
+			{ToAccountID: account2.ID, Amount: 60},
+			{ToAccountID: account2.ID, Amount: 60},
+		},
+	})
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	account, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), account.Balance)
+}
+
+func TestBatchTransferTxBestEffort(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	account3 := createRandomAccountWithCurrency(t, util.EUR)
+
+	result, err := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: account1.ID,
+		Legs: []BatchTransferLeg{
+			{ToAccountID: account2.ID, Amount: 60},
+			{ToAccountID: account2.ID, Amount: 60},
+			{ToAccountID: account3.ID, Amount: 10},
+			{ToAccountID: account2.ID, Amount: 40},
+		},
+		BestEffort: true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, result.Legs[0].Err)
+	require.True(t, errors.Is(result.Legs[1].Err, ErrInsufficientFunds))
+	require.True(t, errors.Is(result.Legs[2].Err, ErrCurrencyMismatch))
+	require.NoError(t, result.Legs[3].Err)
+
+	// only the succeeded legs are posted
+	account, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, account.Balance)
+
+	transfers, err := testQueries.ListBatchTransfers(context.Background(), sql.NullInt64{Int64: result.Batch.ID, Valid: true})
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// OpenDB opens a database like sql.Open, the rows of the queries run by a Store are counted by its driver
+// *sql.Rows cannot be wrapped, so the span of a query is ended by the rows of the driver, see tracedDB.QueryContext
+func OpenDB(driverName, dataSourceName string) (*sql.DB, error) {
+	// sql.Open doesnt connect, it is only used to look up the driver
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+
+	var connector driver.Connector = dsnConnector{driver: d, dsn: dataSourceName}
+	if dc, ok := d.(driver.DriverContext); ok {
+		connector, err = dc.OpenConnector(dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(countingConnector{connector}), nil
+}
+
+// dsnConnector connects with a driver that cannot create connectors itself, like sql.Open does
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type countingConnector struct {
+	driver.Connector
+}
+
+func (c countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn}, nil
+}
+
+// countingConn counts the rows of the queries with a rowCounter in their ctx
+// the optional interfaces of the connection are passed through, database/sql only sees the ones of the wrapper
+type countingConn struct {
+	driver.Conn
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	counter, ok := ctx.Value(rowCounterKey{}).(*rowCounter)
+	if !ok {
+		return rows, nil
+	}
+	counter.counting = true
+	return &countingRows{Rows: rows, counter: counter}, nil
+}
+
+func (c countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c countingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("driver does not support transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+func (c countingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c countingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c countingConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue falls back to the default conversion of database/sql with driver.ErrSkip
+func (c countingConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// countingRows counts the rows read by Next and reports them to the counter on Close
+// column types are not passed through, the queries of sqlc dont use them
+type countingRows struct {
+	driver.Rows
+	counter *rowCounter
+	rows    int64
+	err     error
+}
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.rows++
+	case err != io.EOF:
+		r.err = err
+	}
+	return err
+}
+
+func (r *countingRows) Close() error {
+	err := r.Rows.Close()
+	r.counter.done(r.rows, r.err)
+	return err
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListStatementEntries(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccount(t), 1000)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	from := time.Now().Add(-time.Minute)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	rows, err := testQueries.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		FromTime:  from,
+		ToTime:    time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+
+	// counterparty comes from the transfer of the entry
+	row := rows[0]
+	require.Equal(t, result.FromEntry.ID, row.ID)
+	require.Equal(t, int64(-10), row.Amount)
+	require.Equal(t, result.Transfer.ID, row.TransferID.Int64)
+	require.Equal(t, account1.ID, row.FromAccountID.Int64)
+	require.Equal(t, account2.ID, row.ToAccountID.Int64)
+	require.False(t, row.IsFee)
+
+	total, err := testQueries.SumEntriesSince(context.Background(), SumEntriesSinceParams{
+		AccountID: account1.ID,
+		CreatedAt: from,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-10), total)
+
+	// no entries after the transfer
+	total, err = testQueries.SumEntriesSince(context.Background(), SumEntriesSinceParams{
+		AccountID: account1.ID,
+		CreatedAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Zero(t, total)
+
+	// opening balance is calculated backwards from the current balance
+	statement, err := store.StatementTx(context.Background(), StatementTxParams{
+		AccountID: account2.ID,
+		From:      from,
+		To:        time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, statement.OpeningBalance)
+	require.Equal(t, account2.Balance+10, statement.ClosingBalance)
+	require.Len(t, statement.Lines, 1)
+	require.Equal(t, account1.ID, statement.Lines[0].CounterpartyAccountID.Int64)
+	require.Equal(t, statement.ClosingBalance, statement.Lines[0].Balance)
+}
+
+func TestStatementTxWithFee(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	feeAccount := createRandomAccountWithCurrency(t, util.USD)
+	store := newFeeStore(t, feeAccount, 5)
+
+	from := time.Now().Add(-time.Minute)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	arg := StatementTxParams{
+		AccountID: account1.ID,
+		From:      from,
+		To:        time.Now().Add(time.Minute),
+	}
+	statement, err := store.StatementTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, statement.Lines, 2)
+
+	// the amount goes to the recipient, the fee to the fee account
+	require.Equal(t, result.FromEntry.ID, statement.Lines[0].EntryID)
+	require.False(t, statement.Lines[0].Fee)
+	require.Equal(t, account2.ID, statement.Lines[0].CounterpartyAccountID.Int64)
+
+	require.Equal(t, result.FromFeeEntry.ID, statement.Lines[1].EntryID)
+	require.True(t, statement.Lines[1].Fee)
+	require.Equal(t, int64(-5), statement.Lines[1].Amount)
+	require.Equal(t, feeAccount.ID, statement.Lines[1].CounterpartyAccountID.Int64)
+	require.Equal(t, int64(85), statement.ClosingBalance)
+
+	arg.AccountID = feeAccount.ID
+	statement, err = store.StatementTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, statement.Lines, 1)
+	require.Equal(t, result.FeeEntry.ID, statement.Lines[0].EntryID)
+	require.True(t, statement.Lines[0].Fee)
+	require.Equal(t, account1.ID, statement.Lines[0].CounterpartyAccountID.Int64)
+
+	arg.AccountID = account2.ID
+	statement, err = store.StatementTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, statement.Lines, 1)
+	require.False(t, statement.Lines[0].Fee)
+	require.Equal(t, account1.ID, statement.Lines[0].CounterpartyAccountID.Int64)
+}
+
+func TestListEntriesAfter(t *testing.T) {
+	account := createRandomAccount(t)
+
+	entries := make([]Entry, 3)
+	for i := range entries {
+		entry, err := testQueries.CreateEntry(context.Background(), CreateEntryParams{
+			AccountID: account.ID,
+			Amount:    util.RandomMoney(),
+		})
+		require.NoError(t, err)
+		entries[i] = entry
+	}
+
+	arg := ListEntriesAfterParams{
+		AccountID: account.ID,
+		Limit:     2,
+	}
+
+	page, err := testQueries.ListEntriesAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, entries[:2], page)
+
+	// next page starts after the last entry of the previous one
+	arg.AfterCreatedAt = page[1].CreatedAt
+	arg.AfterID = page[1].ID
+	page, err = testQueries.ListEntriesAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, entries[2:], page)
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"context"
+)
+
+// ExpectedSchemaVersion is the version of the last migration in db/migration, the one this code is written for
+// it must be increased with every new migration
+const ExpectedSchemaVersion = 19
+
+// SchemaVersion is the migration state of the db, as recorded by golang-migrate
+type SchemaVersion struct {
+	Version int64 `json:"version"`
+	// Dirty is true when a migration failed halfway, and the schema has to be fixed by hand
+	Dirty bool `json:"dirty"`
+}
+
+// Ping checks that the db can be reached
+func (store *SQLStore) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+// GetSchemaVersion returns the version of the last migration run on the db
+// the schema_migrations table is managed by golang-migrate, so it is not known to sqlc
+func (store *SQLStore) GetSchemaVersion(ctx context.Context) (SchemaVersion, error) {
+	var version SchemaVersion
+	err := store.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version.Version, &version.Dirty)
+	return version, err
+}
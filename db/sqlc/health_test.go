@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedSchemaVersion(t *testing.T) {
+	files, err := ioutil.ReadDir("../migration")
+	require.NoError(t, err)
+
+	// e.g. 000017_add_journal_entries.up.sql
+	name := regexp.MustCompile(`^(\d+)_\w+\.up\.sql$`)
+
+	var latest int64
+	for _, file := range files {
+		match := name.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		require.NoError(t, err)
+		if version > latest {
+			latest = version
+		}
+	}
+
+	require.Equal(t, latest, int64(ExpectedSchemaVersion))
+}
+
+func TestGetSchemaVersion(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	err := store.Ping(context.Background())
+	require.NoError(t, err)
+
+	// the tests run on a db migrated up to the latest version
+	version, err := store.GetSchemaVersion(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion{Version: ExpectedSchemaVersion}, version)
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Statuses of a hold
+// a pending hold past its expiry time is expired, it no longer reserves funds and cannot be captured
+const (
+	HoldStatusPending  = "pending"
+	HoldStatusCaptured = "captured"
+	HoldStatusVoided   = "voided"
+	HoldStatusExpired  = "expired"
+)
+
+// Different types of error returned by the hold transactions
+var (
+	// ErrHoldNotPending is returned when a captured or voided hold is captured or voided again
+	ErrHoldNotPending = errors.New("hold is not pending")
+	// ErrHoldExpired is returned when an expired hold is captured or voided
+	ErrHoldExpired = errors.New("hold has expired")
+	// ErrCaptureExceedsHold is returned when more than the held amount is captured
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds the held amount")
+)
+
+// EffectiveStatus returns the status of the hold at the given time, pending holds become expired after their expiry time
+func (hold Hold) EffectiveStatus(now time.Time) string {
+	if hold.Status == HoldStatusPending && !now.Before(hold.ExpiresAt) {
+		return HoldStatusExpired
+	}
+	return hold.Status
+}
+
+// AuthorizeTxParams contains the input parameters of the authorize transaction
+type AuthorizeTxParams struct {
+	AccountID   int64     `json:"account_id"`
+	ToAccountID int64     `json:"to_account_id"`
+	Amount      int64     `json:"amount"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// AuthorizeTx reserves funds of an account with a hold
+// the hold reduces the available balance of the account, but not its balance, until it is captured, voided or expires
+func (store *SQLStore) AuthorizeTx(ctx context.Context, arg AuthorizeTxParams) (Hold, error) {
+	var hold Hold
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		// lock the account, so concurrent holds and transfers cannot spend the same funds
+		account, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkAccountActive(account); err != nil {
+			return err
+		}
+
+		held, err := q.GetHeldAmount(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		// the capture charges the fee on top of the amount, so it is reserved too
+		// the fee of a partial capture is not higher, since fees dont decrease with the amount
+		var holdFee int64
+		if store.fees != nil {
+			quote, err := store.fees.Quote(account.Currency, arg.Amount)
+			if err != nil {
+				return err
+			}
+			holdFee = quote.Fee
+		}
+
+		if account.Balance-held-arg.Amount-holdFee < -account.OverdraftLimit {
+			return fmt.Errorf("account [%d]: %w", arg.AccountID, ErrInsufficientFunds)
+		}
+
+		hold, err = q.CreateHold(ctx, CreateHoldParams{
+			AccountID:   arg.AccountID,
+			ToAccountID: arg.ToAccountID,
+			Amount:      arg.Amount,
+			Fee:         holdFee,
+			ExpiresAt:   arg.ExpiresAt,
+		})
+		return err
+	})
+
+	return hold, err
+}
+
+// CaptureTxParams contains the input parameters of the capture transaction
+type CaptureTxParams struct {
+	HoldID int64 `json:"hold_id"`
+	// Amount is captured, the rest of the hold is released
+	// whole hold is captured when it is zero
+	Amount int64 `json:"amount"`
+}
+
+// CaptureTxResult is the result of the capture transaction
+type CaptureTxResult struct {
+	Hold     Hold             `json:"hold"`
+	Transfer TransferTxResult `json:"transfer"`
+}
+
+// CaptureTx turns a pending hold into a transfer from the held account to the to account of the hold
+func (store *SQLStore) CaptureTx(ctx context.Context, arg CaptureTxParams) (CaptureTxResult, error) {
+	var result CaptureTxResult
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		hold, err := lockPendingHold(ctx, q, arg.HoldID)
+		if err != nil {
+			return err
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount > hold.Amount {
+			return fmt.Errorf("hold [%d] of %d: %w", hold.ID, hold.Amount, ErrCaptureExceedsHold)
+		}
+
+		// the funds reserved by the hold, including its fee, can be spent by the transfer
+		// the fee is charged on the captured amount
+		result.Transfer, err = transferTx(ctx, q, store.fees, store.rates, TransferTxParams{
+			FromAccountID: hold.AccountID,
+			ToAccountID:   hold.ToAccountID,
+			Amount:        amount,
+			releasedHold:  hold.Amount + hold.Fee,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Hold, err = q.CaptureHold(ctx, CaptureHoldParams{
+			ID:             hold.ID,
+			CapturedAmount: amount,
+			TransferID:     sql.NullInt64{Int64: result.Transfer.Transfer.ID, Valid: true},
+		})
+		return err
+	})
+	if err == nil {
+		countTransfer(result.Transfer)
+	}
+
+	return result, err
+}
+
+// VoidTx releases the funds reserved by a pending hold
+func (store *SQLStore) VoidTx(ctx context.Context, holdID int64) (Hold, error) {
+	var hold Hold
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		if _, err := lockPendingHold(ctx, q, holdID); err != nil {
+			return err
+		}
+
+		var err error
+		hold, err = q.VoidHold(ctx, holdID)
+		return err
+	})
+
+	return hold, err
+}
+
+// lockPendingHold locks the hold until the end of the tx, and checks that it can still be captured or voided
+func lockPendingHold(ctx context.Context, q *Queries, holdID int64) (Hold, error) {
+	hold, err := q.GetHoldForUpdate(ctx, holdID)
+	if err != nil {
+		return hold, err
+	}
+
+	switch hold.EffectiveStatus(time.Now()) {
+	case HoldStatusPending:
+		return hold, nil
+	case HoldStatusExpired:
+		return hold, fmt.Errorf("hold [%d]: %w", hold.ID, ErrHoldExpired)
+	default:
+		return hold, fmt.Errorf("hold [%d] is %s: %w", hold.ID, hold.Status, ErrHoldNotPending)
+	}
+}
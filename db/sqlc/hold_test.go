@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomHold(t *testing.T, store Store, from Account, to Account, amount int64) Hold {
+	hold, err := store.AuthorizeTx(context.Background(), AuthorizeTxParams{
+		AccountID:   from.ID,
+		ToAccountID: to.ID,
+		Amount:      amount,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusPending, hold.Status)
+	require.Equal(t, amount, hold.Amount)
+
+	return hold
+}
+
+func TestAuthorizeTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	createRandomHold(t, store, account1, account2, 60)
+
+	// hold reduces the available balance, but not the balance
+	account, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), account.Balance)
+
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(60), held)
+
+	// held funds cannot be reserved again
+	_, err = store.AuthorizeTx(context.Background(), AuthorizeTxParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      50,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	// or spent by a transfer
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        50,
+	})
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+}
+
+func TestCaptureTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	// whole balance is held, the capture can still spend it
+	hold := createRandomHold(t, store, account1, account2, 100)
+
+	result, err := store.CaptureTx(context.Background(), CaptureTxParams{HoldID: hold.ID, Amount: 40})
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusCaptured, result.Hold.Status)
+	require.Equal(t, int64(40), result.Hold.CapturedAmount)
+	require.Equal(t, result.Transfer.Transfer.ID, result.Hold.TransferID.Int64)
+	require.Equal(t, int64(60), result.Transfer.FromAccount.Balance)
+	require.Equal(t, account2.Balance+40, result.Transfer.ToAccount.Balance)
+
+	// rest of the partially captured hold is released
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	_, err = store.CaptureTx(context.Background(), CaptureTxParams{HoldID: hold.ID})
+	require.True(t, errors.Is(err, ErrHoldNotPending))
+}
+
+func TestCaptureTxWithFee(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 45)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	feeAccount := createRandomAccountWithCurrency(t, util.USD)
+	store := newFeeStore(t, feeAccount, 5)
+
+	// the fee is reserved on top of the amount
+	_, err := store.AuthorizeTx(context.Background(), AuthorizeTxParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      41,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	hold := createRandomHold(t, store, account1, account2, 40)
+	require.Equal(t, int64(5), hold.Fee)
+
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(45), held)
+
+	// so the whole balance is held, and the capture can still charge the fee
+	result, err := store.CaptureTx(context.Background(), CaptureTxParams{HoldID: hold.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), result.Transfer.Transfer.Fee)
+	require.Zero(t, result.Transfer.FromAccount.Balance)
+}
+
+func TestCaptureTxExceedsHold(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	hold := createRandomHold(t, store, account1, account2, 50)
+
+	_, err := store.CaptureTx(context.Background(), CaptureTxParams{HoldID: hold.ID, Amount: 51})
+	require.True(t, errors.Is(err, ErrCaptureExceedsHold))
+}
+
+func TestVoidTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	hold := createRandomHold(t, store, account1, account2, 100)
+
+	voided, err := store.VoidTx(context.Background(), hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusVoided, voided.Status)
+
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	_, err = store.CaptureTx(context.Background(), CaptureTxParams{HoldID: hold.ID})
+	require.True(t, errors.Is(err, ErrHoldNotPending))
+}
+
+func TestExpiredHold(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	hold, err := store.AuthorizeTx(context.Background(), AuthorizeTxParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      100,
+		ExpiresAt:   time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusExpired, hold.EffectiveStatus(time.Now()))
+
+	// expired holds dont reserve anything
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	_, err = store.CaptureTx(context.Background(), CaptureTxParams{HoldID: hold.ID})
+	require.True(t, errors.Is(err, ErrHoldExpired))
+}
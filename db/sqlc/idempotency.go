@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrIdempotencyKeyReused is returned when a client sends an idempotency key again with a different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// IdempotencyParams identifies a client request that must be executed at most once
+type IdempotencyParams struct {
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+	RequestPath    string `json:"request_path"`
+	// fingerprint of the request, a key can only be reused with the same fingerprint
+	RequestHash string `json:"request_hash"`
+}
+
+// IdempotentTxResult is the result of an idempotent transaction
+type IdempotentTxResult struct {
+	// JSON encoded result of the first execution of the request
+	Response []byte `json:"response"`
+	// Replayed is true when the request was already executed before and the stored response is returned
+	Replayed bool `json:"replayed"`
+}
+
+// IdempotentTransferTx performs TransferTx at most once for the given idempotency key
+// the key and the response are stored in the same db transaction as the transfer
+func (store *SQLStore) IdempotentTransferTx(ctx context.Context, idem IdempotencyParams, arg TransferTxParams) (IdempotentTxResult, error) {
+	var transfer TransferTxResult
+
+	result, err := store.execIdempotentTx(ctx, idem, func(q *Queries) (interface{}, error) {
+		var err error
+		transfer, err = transferTx(ctx, q, store.fees, store.rates, arg)
+		return transfer, err
+	})
+	// a replayed request didnt create a transfer
+	if err == nil && !result.Replayed {
+		countTransfer(transfer)
+	}
+	return result, err
+}
+
+// IdempotentCreateAccountTx creates an account at most once for the given idempotency key
+func (store *SQLStore) IdempotentCreateAccountTx(ctx context.Context, idem IdempotencyParams, arg CreateAccountParams) (IdempotentTxResult, error) {
+	return store.execIdempotentTx(ctx, idem, func(q *Queries) (interface{}, error) {
+		return q.CreateAccount(ctx, arg)
+	})
+}
+
+// execIdempotentTx claims the idempotency key and runs fn within a single db transaction
+// if the key was completed before, the stored response is returned and fn is not called
+func (store *SQLStore) execIdempotentTx(ctx context.Context, idem IdempotencyParams, fn func(*Queries) (interface{}, error)) (IdempotentTxResult, error) {
+	var result IdempotentTxResult
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		// reset the result of a previous attempt, the tx might be retried
+		result = IdempotentTxResult{}
+
+		// concurrent requests with the same key block here until the first one commits or rolls back,
+		// so duplicates are serialized instead of being executed twice
+		err := q.CreateIdempotencyKey(ctx, CreateIdempotencyKeyParams{
+			Username:       idem.Username,
+			IdempotencyKey: idem.IdempotencyKey,
+			RequestPath:    idem.RequestPath,
+			RequestHash:    idem.RequestHash,
+		})
+		if err != nil {
+			return err
+		}
+
+		key, err := q.GetIdempotencyKeyForUpdate(ctx, GetIdempotencyKeyForUpdateParams{
+			Username:       idem.Username,
+			IdempotencyKey: idem.IdempotencyKey,
+		})
+		if err != nil {
+			return err
+		}
+
+		if key.RequestPath != idem.RequestPath || key.RequestHash != idem.RequestHash {
+			return ErrIdempotencyKeyReused
+		}
+
+		if key.ResponseBody != nil {
+			result.Response = key.ResponseBody
+			result.Replayed = true
+			return nil
+		}
+
+		response, err := fn(q)
+		if err != nil {
+			return err
+		}
+
+		result.Response, err = json.Marshal(response)
+		if err != nil {
+			return err
+		}
+
+		return q.SetIdempotencyKeyResponse(ctx, SetIdempotencyKeyResponseParams{
+			Username:       idem.Username,
+			IdempotencyKey: idem.IdempotencyKey,
+			ResponseBody:   result.Response,
+		})
+	})
+	return result, err
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentTransferTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccount(t), 1000)
+	account2 := createRandomAccount(t)
+
+	idem := IdempotencyParams{
+		Username:       account1.Owner,
+		IdempotencyKey: util.RandomString(16),
+		RequestPath:    "/transfers",
+		RequestHash:    util.RandomString(32),
+	}
+	arg := TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	}
+
+	n := 5
+	errs := make(chan error)
+	results := make(chan IdempotentTxResult)
+
+	// run n concurrent requests with the same key, only one of them may execute the transfer
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := store.IdempotentTransferTx(context.Background(), idem, arg)
+
+			errs <- err
+			results <- result
+		}()
+	}
+
+	var response []byte
+	replayed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		require.NoError(t, err)
+
+		result := <-results
+		require.NotEmpty(t, result.Response)
+
+		// every request gets exactly the same response
+		if response == nil {
+			response = result.Response
+		}
+		require.Equal(t, response, result.Response)
+
+		if result.Replayed {
+			replayed++
+		}
+	}
+	require.Equal(t, n-1, replayed)
+
+	var transferResult TransferTxResult
+	err := json.Unmarshal(response, &transferResult)
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, transferResult.Transfer.FromAccountID)
+
+	// money moved only once
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-arg.Amount, updatedAccount1.Balance)
+
+	// same key with another request is rejected
+	idem.RequestHash = util.RandomString(32)
+	_, err = store.IdempotentTransferTx(context.Background(), idem, arg)
+	require.EqualError(t, err, ErrIdempotencyKeyReused.Error())
+}
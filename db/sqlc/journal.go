@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/keremakillioglu/simplebank/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Different types of error returned by PostJournalTx, the whole transaction is rolled back in these cases
+var (
+	// ErrInvalidLeg is returned when a leg has no account, amount or currency
+	ErrInvalidLeg = errors.New("invalid journal leg")
+	// ErrUnbalancedJournal is returned when the legs of a currency dont add up to zero
+	ErrUnbalancedJournal = errors.New("journal is not balanced")
+)
+
+// Leg is a line of a journal, it adds Amount to the balance of the account with an entry
+type Leg struct {
+	AccountID int64 `json:"account_id"`
+	// Amount is negative for a debit, it is in Currency which must be the currency of the account
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// journalLeg is a leg of a journal posted by the store itself
+// a leg exchanged from another currency balances the journal with its source amount instead,
+// e.g. the credit of a cross currency transfer balances the debit of the sender with the rate checked by transferTx
+type journalLeg struct {
+	Leg
+	sourceAmount   int64
+	sourceCurrency string
+}
+
+// JournalTxResult is the result of the journal transaction
+type JournalTxResult struct {
+	Journal JournalEntry `json:"journal"`
+	// Entries are in the order of the legs
+	Entries []Entry `json:"entries"`
+	// Accounts are the updated accounts of the legs, in the order of their ids
+	Accounts []Account `json:"accounts"`
+}
+
+// PostJournalTx posts a balanced set of legs as one journal, every leg gets an entry linked to the journal
+// the legs of each currency must add up to zero, so money is only moved and never created
+// money is exchanged by transfers only, where the rate is checked
+func (store *SQLStore) PostJournalTx(ctx context.Context, legs []Leg) (JournalTxResult, error) {
+	var result JournalTxResult
+
+	journalLegs := make([]journalLeg, len(legs))
+	for i, leg := range legs {
+		journalLegs[i] = journalLeg{Leg: leg}
+	}
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		var err error
+		result, err = postJournal(ctx, q, journalParams{legs: journalLegs})
+		return err
+	})
+	return result, err
+}
+
+// journalParams contains the input parameters of postJournal
+type journalParams struct {
+	legs []journalLeg
+	// transferID links the entries to the transfer they post, it is null for other journals
+	transferID sql.NullInt64
+	// releasedHold is the part of the held funds of an account that the journal can spend, see CaptureTx
+	releasedHold map[int64]int64
+}
+
+// postJournal runs the queries of a journal with the given Queries object
+// it must be called inside of a db transaction, see execTx
+func postJournal(ctx context.Context, q *Queries, arg journalParams) (result JournalTxResult, err error) {
+	accountIDs := make([]int64, len(arg.legs))
+	for i, leg := range arg.legs {
+		accountIDs[i] = leg.AccountID
+	}
+
+	q, span := startTxSpan(ctx, q, "postJournal",
+		attribute.Int("journal.legs", len(arg.legs)),
+		attribute.Array("journal.account_ids", accountIDs),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if err = validateLegs(arg.legs); err != nil {
+		return
+	}
+
+	result.Journal, err = q.CreateJournalEntry(ctx)
+	if err != nil {
+		return
+	}
+	span.SetAttributes(attribute.Int64("journal.id", result.Journal.ID))
+
+	result.Entries = make([]Entry, len(arg.legs))
+	amounts := make(map[int64]int64)
+	for i, leg := range arg.legs {
+		result.Entries[i], err = q.CreateEntry(ctx, CreateEntryParams{
+			AccountID:  leg.AccountID,
+			Amount:     leg.Amount,
+			TransferID: arg.transferID,
+			JournalID:  sql.NullInt64{Int64: result.Journal.ID, Valid: true},
+		})
+		if err != nil {
+			return
+		}
+		amounts[leg.AccountID] += leg.Amount
+	}
+
+	result.Accounts, err = addMoneyInOrder(ctx, q, amounts)
+	if err != nil {
+		return
+	}
+
+	// the checks run after the update, when the rows are locked and cannot be changed concurrently
+	accounts := make(map[int64]Account, len(result.Accounts))
+	for _, account := range result.Accounts {
+		accounts[account.ID] = account
+	}
+
+	for _, leg := range arg.legs {
+		if account := accounts[leg.AccountID]; account.Currency != leg.Currency {
+			err = fmt.Errorf("%w: account [%d] is in %s, not %s", ErrCurrencyMismatch, account.ID, account.Currency, leg.Currency)
+			return
+		}
+	}
+
+	for _, account := range result.Accounts {
+		if err = checkAccountActive(account); err != nil {
+			return
+		}
+	}
+
+	for _, account := range result.Accounts {
+		if amounts[account.ID] >= 0 {
+			continue
+		}
+
+		var held int64
+		held, err = q.GetHeldAmount(ctx, account.ID)
+		if err != nil {
+			return
+		}
+
+		// funds reserved by pending holds cannot be spent
+		if account.Balance-(held-arg.releasedHold[account.ID]) < -account.OverdraftLimit {
+			err = fmt.Errorf("account [%d]: %w", account.ID, ErrInsufficientFunds)
+			return
+		}
+	}
+
+	return
+}
+
+// validateLegs checks the legs before anything is written, their currencies are checked against the accounts later
+func validateLegs(legs []journalLeg) error {
+	if len(legs) < 2 {
+		return fmt.Errorf("%w: a journal needs at least two legs", ErrInvalidLeg)
+	}
+
+	// currencies are kept in the order of the legs, so the same journal always fails with the same error
+	var currencies []string
+	totals := make(map[string]int64)
+	add := func(currency string, amount int64) {
+		if _, ok := totals[currency]; !ok {
+			currencies = append(currencies, currency)
+		}
+		totals[currency] += amount
+	}
+
+	for i, leg := range legs {
+		if leg.AccountID <= 0 || leg.Amount == 0 || len(leg.Currency) == 0 {
+			return fmt.Errorf("%w: leg %d", ErrInvalidLeg, i)
+		}
+
+		if len(leg.sourceCurrency) == 0 {
+			add(leg.Currency, leg.Amount)
+			continue
+		}
+
+		// the source amount moves in the same direction as the amount
+		if leg.sourceCurrency == leg.Currency || leg.sourceAmount == 0 || (leg.sourceAmount < 0) != (leg.Amount < 0) {
+			return fmt.Errorf("%w: leg %d has an invalid source amount", ErrInvalidLeg, i)
+		}
+		add(leg.sourceCurrency, leg.sourceAmount)
+	}
+
+	for _, currency := range currencies {
+		if totals[currency] != 0 {
+			return fmt.Errorf("%w: %s legs add up to %d", ErrUnbalancedJournal, currency, totals[currency])
+		}
+	}
+	return nil
+}
+
+// addMoneyInOrder adds the amounts to the balances of the accounts, in the order of their ids
+// every tx locks the account rows in the same order, so two transfers cannot wait for each other
+func addMoneyInOrder(ctx context.Context, q *Queries, amounts map[int64]int64) ([]Account, error) {
+	ids := make([]int64, 0, len(amounts))
+	for id := range amounts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	accounts := make([]Account, 0, len(ids))
+	for _, id := range ids {
+		account, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     id,
+			Amount: amounts[id],
+		})
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
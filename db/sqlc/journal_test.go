@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLegs(t *testing.T) {
+	testCases := []struct {
+		name string
+		legs []journalLeg
+		err  error
+	}{
+		{
+			name: "ThreeLegs",
+			legs: []journalLeg{
+				{Leg: Leg{AccountID: 1, Amount: -100, Currency: util.USD}},
+				{Leg: Leg{AccountID: 2, Amount: 90, Currency: util.USD}},
+				{Leg: Leg{AccountID: 3, Amount: 10, Currency: util.USD}},
+			},
+		},
+		{
+			name: "Exchanged",
+			legs: []journalLeg{
+				{Leg: Leg{AccountID: 1, Amount: -100, Currency: util.USD}},
+				{Leg: Leg{AccountID: 2, Amount: 92, Currency: util.EUR}, sourceAmount: 100, sourceCurrency: util.USD},
+			},
+		},
+		{
+			name: "OneLeg",
+			legs: []journalLeg{{Leg: Leg{AccountID: 1, Amount: -100, Currency: util.USD}}},
+			err:  ErrInvalidLeg,
+		},
+		{
+			name: "ZeroAmount",
+			legs: []journalLeg{
+				{Leg: Leg{AccountID: 1, Amount: 0, Currency: util.USD}},
+				{Leg: Leg{AccountID: 2, Amount: 0, Currency: util.USD}},
+			},
+			err: ErrInvalidLeg,
+		},
+		{
+			name: "Unbalanced",
+			legs: []journalLeg{
+				{Leg: Leg{AccountID: 1, Amount: -100, Currency: util.USD}},
+				{Leg: Leg{AccountID: 2, Amount: 99, Currency: util.USD}},
+			},
+			err: ErrUnbalancedJournal,
+		},
+		{
+			// each currency must balance on its own
+			name: "UnbalancedCurrency",
+			legs: []journalLeg{
+				{Leg: Leg{AccountID: 1, Amount: -100, Currency: util.USD}},
+				{Leg: Leg{AccountID: 2, Amount: 100, Currency: util.EUR}},
+			},
+			err: ErrUnbalancedJournal,
+		},
+		{
+			name: "ExchangedInWrongDirection",
+			legs: []journalLeg{
+				{Leg: Leg{AccountID: 1, Amount: -100, Currency: util.USD}},
+				{Leg: Leg{AccountID: 2, Amount: 92, Currency: util.EUR}, sourceAmount: -100, sourceCurrency: util.USD},
+			},
+			err: ErrInvalidLeg,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLegs(tc.legs)
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, tc.err), err)
+		})
+	}
+}
+
+func TestPostJournalTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+	account3 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+
+	// a transfer, a fee and a tax in one journal
+	legs := []Leg{
+		{AccountID: account1.ID, Amount: -100, Currency: util.USD},
+		{AccountID: account2.ID, Amount: 85, Currency: util.USD},
+		{AccountID: account3.ID, Amount: 10, Currency: util.USD},
+		{AccountID: account3.ID, Amount: 5, Currency: util.USD},
+	}
+
+	result, err := store.PostJournalTx(context.Background(), legs)
+	require.NoError(t, err)
+	require.NotZero(t, result.Journal.ID)
+
+	require.Len(t, result.Entries, len(legs))
+	for i, entry := range result.Entries {
+		require.Equal(t, legs[i].AccountID, entry.AccountID)
+		require.Equal(t, legs[i].Amount, entry.Amount)
+		require.Equal(t, result.Journal.ID, entry.JournalID.Int64)
+		require.False(t, entry.TransferID.Valid)
+	}
+
+	// accounts are updated once each, in the order of their ids
+	require.Len(t, result.Accounts, 3)
+	require.Equal(t, account1.ID, result.Accounts[0].ID)
+	require.Equal(t, int64(900), result.Accounts[0].Balance)
+	require.Equal(t, int64(85), result.Accounts[1].Balance)
+	require.Equal(t, int64(15), result.Accounts[2].Balance)
+
+	entries, err := testQueries.ListJournalEntries(context.Background(), sql.NullInt64{Int64: result.Journal.ID, Valid: true})
+	require.NoError(t, err)
+	require.Equal(t, result.Entries, entries)
+}
+
+func TestPostJournalTxRollback(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 50)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	account3 := createRandomAccountWithCurrency(t, util.EUR)
+
+	testCases := []struct {
+		name string
+		legs []Leg
+		err  error
+	}{
+		{
+			name: "InsufficientFunds",
+			legs: []Leg{
+				{AccountID: account1.ID, Amount: -100, Currency: util.USD},
+				{AccountID: account2.ID, Amount: 100, Currency: util.USD},
+			},
+			err: ErrInsufficientFunds,
+		},
+		{
+			// the legs balance, but the account is not in the currency of its leg
+			name: "CurrencyMismatch",
+			legs: []Leg{
+				{AccountID: account1.ID, Amount: -10, Currency: util.USD},
+				{AccountID: account3.ID, Amount: 10, Currency: util.USD},
+			},
+			err: ErrCurrencyMismatch,
+		},
+		{
+			// only transfers exchange money, the legs of a journal must balance in each currency
+			name: "Exchanged",
+			legs: []Leg{
+				{AccountID: account1.ID, Amount: -10, Currency: util.USD},
+				{AccountID: account3.ID, Amount: 1000000, Currency: util.EUR},
+			},
+			err: ErrUnbalancedJournal,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := store.PostJournalTx(context.Background(), tc.legs)
+			require.True(t, errors.Is(err, tc.err), err)
+
+			// nothing of the journal is left behind
+			account, err := testQueries.GetAccount(context.Background(), account1.ID)
+			require.NoError(t, err)
+			require.Equal(t, account1.Balance, account.Balance)
+		})
+	}
+}
+
+func TestTransferTxJournal(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	feeAccount := createRandomAccountWithCurrency(t, util.USD)
+	store := newFeeStore(t, feeAccount, 3)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	// all entries of the transfer, including the fee, belong to one journal
+	journalID := result.FromEntry.JournalID
+	require.True(t, journalID.Valid)
+
+	entries, err := testQueries.ListJournalEntries(context.Background(), journalID)
+	require.NoError(t, err)
+	require.Equal(t, []Entry{result.FromEntry, result.ToEntry, *result.FromFeeEntry, *result.FeeEntry}, entries)
+}
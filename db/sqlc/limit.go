@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/keremakillioglu/simplebank/fx"
+)
+
+// Roles of a user, only admins can change the transfer limits
+const (
+	UserRoleCustomer = "customer"
+	UserRoleAdmin    = "admin"
+)
+
+// Names of the transfer limits, reported by LimitError
+const (
+	LimitPerTransaction = "per_transaction"
+	LimitDaily          = "daily"
+	LimitMonthly        = "monthly"
+	LimitHourlyCount    = "hourly_count"
+)
+
+// ErrLimitExceeded is wrapped by LimitError, use errors.As to find out which limit is hit
+var ErrLimitExceeded = errors.New("transfer limit exceeded")
+
+// LimitError is returned when a transfer would exceed one of the limits of the from account
+type LimitError struct {
+	AccountID int64
+	// Username is set when the limit of the owner is hit, its totals are summed over all of its accounts
+	Username string
+	// Limit is the name of the limit hit, e.g. LimitDaily
+	Limit string
+	// Max is the value of the limit, Actual is the total it would be with the transfer
+	Max    int64
+	Actual int64
+}
+
+func (e *LimitError) Error() string {
+	if len(e.Username) > 0 {
+		return fmt.Sprintf("user [%s]: %s: %s limit is %d, transfer would make it %d", e.Username, ErrLimitExceeded, e.Limit, e.Max, e.Actual)
+	}
+	return fmt.Sprintf("account [%d]: %s: %s limit is %d, transfer would make it %d", e.AccountID, ErrLimitExceeded, e.Limit, e.Max, e.Actual)
+}
+
+// Unwrap makes errors.Is(err, ErrLimitExceeded) work for every limit
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// TransferLimits are the limits that apply to the outgoing transfers of an account, zero means no limit
+// amounts are in the currency of the account, daily and monthly are the last 24 hours and 30 days
+// the amounts of a user limit are in the currency of the sending account, see ownerOutgoingTransferTotals
+type TransferLimits struct {
+	DailyAmount          int64 `json:"daily_amount"`
+	MonthlyAmount        int64 `json:"monthly_amount"`
+	PerTransactionAmount int64 `json:"per_transaction_amount"`
+	HourlyCount          int64 `json:"hourly_count"`
+}
+
+// EffectiveTransferLimits merges the limits of an account, its owner and the system defaults
+// each limit is taken from the most specific row that sets it: account, then user, then default
+func EffectiveTransferLimits(rows []TransferLimit) TransferLimits {
+	var account, user, defaults *TransferLimit
+	for i := range rows {
+		switch {
+		case rows[i].AccountID.Valid:
+			account = &rows[i]
+		case rows[i].Username.Valid:
+			user = &rows[i]
+		default:
+			defaults = &rows[i]
+		}
+	}
+
+	var limits TransferLimits
+	for _, row := range []*TransferLimit{defaults, user, account} {
+		if row == nil {
+			continue
+		}
+		if row.DailyAmount.Valid {
+			limits.DailyAmount = row.DailyAmount.Int64
+		}
+		if row.MonthlyAmount.Valid {
+			limits.MonthlyAmount = row.MonthlyAmount.Int64
+		}
+		if row.PerTransactionAmount.Valid {
+			limits.PerTransactionAmount = row.PerTransactionAmount.Int64
+		}
+		if row.HourlyCount.Valid {
+			limits.HourlyCount = int64(row.HourlyCount.Int32)
+		}
+	}
+	return limits
+}
+
+// scopedTransferLimits splits the limits by the totals they are checked against
+// the user row is checked against the totals of all accounts of the owner, the account row against the account itself
+// the defaults apply to each account, unless the user row sets the same limit
+func scopedTransferLimits(rows []TransferLimit) (account, user TransferLimits) {
+	var accountRows []TransferLimit
+	var defaults *TransferLimit
+	for i := range rows {
+		switch {
+		case rows[i].AccountID.Valid:
+			accountRows = append(accountRows, rows[i])
+		case rows[i].Username.Valid:
+			user = EffectiveTransferLimits(rows[i : i+1])
+		default:
+			row := rows[i]
+			defaults = &row
+		}
+	}
+
+	if defaults != nil {
+		if user.DailyAmount > 0 {
+			defaults.DailyAmount = sql.NullInt64{}
+		}
+		if user.MonthlyAmount > 0 {
+			defaults.MonthlyAmount = sql.NullInt64{}
+		}
+		if user.PerTransactionAmount > 0 {
+			defaults.PerTransactionAmount = sql.NullInt64{}
+		}
+		if user.HourlyCount > 0 {
+			defaults.HourlyCount = sql.NullInt32{}
+		}
+		accountRows = append(accountRows, *defaults)
+	}
+	return EffectiveTransferLimits(accountRows), user
+}
+
+// checkTransferLimits checks the limits of the from account and its owner after the transfer is posted, so the totals include it
+// the from account row is locked by the balance update, concurrent transfers of the account wait for this tx
+// and see its transfer in their totals, the user row is locked the same way for the transfers of its other accounts
+func checkTransferLimits(ctx context.Context, q *Queries, rates fx.RateProvider, from Account, amount int64) error {
+	rows, err := q.ListApplicableTransferLimits(ctx, ListApplicableTransferLimitsParams{
+		AccountID: sql.NullInt64{Int64: from.ID, Valid: true},
+		Owner:     sql.NullString{String: from.Owner, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	account, user := scopedTransferLimits(rows)
+
+	if account.PerTransactionAmount > 0 && amount > account.PerTransactionAmount {
+		return &LimitError{AccountID: from.ID, Limit: LimitPerTransaction, Max: account.PerTransactionAmount, Actual: amount}
+	}
+	if user.PerTransactionAmount > 0 && amount > user.PerTransactionAmount {
+		return &LimitError{AccountID: from.ID, Username: from.Owner, Limit: LimitPerTransaction, Max: user.PerTransactionAmount, Actual: amount}
+	}
+
+	// windows end at the start time of the tx, which is also the created_at of the transfer
+	if account.DailyAmount > 0 || account.MonthlyAmount > 0 || account.HourlyCount > 0 {
+		totals, err := q.GetOutgoingTransferTotals(ctx, from.ID)
+		if err != nil {
+			return err
+		}
+
+		limitErr := exceededLimit(account, totals.DailyAmount, totals.MonthlyAmount, totals.HourlyCount)
+		if limitErr != nil {
+			limitErr.AccountID = from.ID
+			return limitErr
+		}
+	}
+
+	if user.DailyAmount > 0 || user.MonthlyAmount > 0 || user.HourlyCount > 0 {
+		// transfers of the other accounts of the owner dont lock the from account, so they wait here instead
+		if _, err := q.GetUserForUpdate(ctx, from.Owner); err != nil {
+			return err
+		}
+
+		daily, monthly, hourlyCount, err := ownerOutgoingTransferTotals(ctx, q, rates, from.Owner, from.Currency)
+		if err != nil {
+			return err
+		}
+
+		limitErr := exceededLimit(user, daily, monthly, hourlyCount)
+		if limitErr != nil {
+			limitErr.AccountID = from.ID
+			limitErr.Username = from.Owner
+			return limitErr
+		}
+	}
+	return nil
+}
+
+// ownerOutgoingTransferTotals sums the outgoing transfers of all accounts of the owner
+// amounts of the other currencies are converted to currency with rates, the provider the transfers are made with
+func ownerOutgoingTransferTotals(ctx context.Context, q *Queries, rates fx.RateProvider, owner string, currency string) (daily, monthly, hourlyCount int64, err error) {
+	rows, err := q.ListOwnerOutgoingTransferTotals(ctx, owner)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	for _, row := range rows {
+		hourlyCount += row.HourlyCount
+		if row.Currency == currency {
+			daily += row.DailyAmount
+			monthly += row.MonthlyAmount
+			continue
+		}
+
+		// a missing rate fails the transfer, the limit cannot be checked without it
+		rate, err := rates.GetRate(ctx, row.Currency, currency)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		convertedDaily, err := convertTotal(rate, row.DailyAmount)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		convertedMonthly, err := convertTotal(rate, row.MonthlyAmount)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		daily += convertedDaily
+		monthly += convertedMonthly
+	}
+	return daily, monthly, hourlyCount, nil
+}
+
+// convertTotal converts a total of transfers, totals too small for the to currency count as zero
+func convertTotal(rate fx.Rate, amount int64) (int64, error) {
+	if amount == 0 {
+		return 0, nil
+	}
+	converted, err := rate.ConvertAmount(amount)
+	if errors.Is(err, fx.ErrAmountTooSmall) {
+		return 0, nil
+	}
+	return converted, err
+}
+
+// exceededLimit returns the first of the daily, monthly and hourly count limits the totals are over, or nil
+func exceededLimit(limits TransferLimits, daily, monthly, hourlyCount int64) *LimitError {
+	switch {
+	case limits.DailyAmount > 0 && daily > limits.DailyAmount:
+		return &LimitError{Limit: LimitDaily, Max: limits.DailyAmount, Actual: daily}
+	case limits.MonthlyAmount > 0 && monthly > limits.MonthlyAmount:
+		return &LimitError{Limit: LimitMonthly, Max: limits.MonthlyAmount, Actual: monthly}
+	case limits.HourlyCount > 0 && hourlyCount > limits.HourlyCount:
+		return &LimitError{Limit: LimitHourlyCount, Max: limits.HourlyCount, Actual: hourlyCount}
+	}
+	return nil
+}
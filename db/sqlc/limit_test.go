@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveTransferLimits(t *testing.T) {
+	rows := []TransferLimit{
+		{
+			AccountID:   sql.NullInt64{Int64: 1, Valid: true},
+			DailyAmount: sql.NullInt64{Int64: 100, Valid: true},
+		},
+		{
+			DailyAmount:   sql.NullInt64{Int64: 1000, Valid: true},
+			MonthlyAmount: sql.NullInt64{Int64: 10000, Valid: true},
+			HourlyCount:   sql.NullInt32{Int32: 10, Valid: true},
+		},
+		{
+			Username:      sql.NullString{String: "owner", Valid: true},
+			MonthlyAmount: sql.NullInt64{Int64: 5000, Valid: true},
+		},
+	}
+
+	// the most specific row wins for each limit, whatever the order of the rows
+	require.Equal(t, TransferLimits{
+		DailyAmount:   100,
+		MonthlyAmount: 5000,
+		HourlyCount:   10,
+	}, EffectiveTransferLimits(rows))
+
+	require.Equal(t, TransferLimits{}, EffectiveTransferLimits(nil))
+}
+
+func TestScopedTransferLimits(t *testing.T) {
+	rows := []TransferLimit{
+		{
+			AccountID:   sql.NullInt64{Int64: 1, Valid: true},
+			DailyAmount: sql.NullInt64{Int64: 100, Valid: true},
+		},
+		{
+			DailyAmount:   sql.NullInt64{Int64: 1000, Valid: true},
+			MonthlyAmount: sql.NullInt64{Int64: 10000, Valid: true},
+			HourlyCount:   sql.NullInt32{Int32: 10, Valid: true},
+		},
+		{
+			Username:      sql.NullString{String: "owner", Valid: true},
+			MonthlyAmount: sql.NullInt64{Int64: 5000, Valid: true},
+		},
+	}
+
+	// the monthly default is replaced by the limit of the user, which is checked against all accounts of the user
+	account, user := scopedTransferLimits(rows)
+	require.Equal(t, TransferLimits{DailyAmount: 100, HourlyCount: 10}, account)
+	require.Equal(t, TransferLimits{MonthlyAmount: 5000}, user)
+
+	account, user = scopedTransferLimits(nil)
+	require.Equal(t, TransferLimits{}, account)
+	require.Equal(t, TransferLimits{}, user)
+}
+
+func TestTransferTxLimits(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	_, err := testQueries.UpsertAccountTransferLimit(context.Background(), UpsertAccountTransferLimitParams{
+		AccountID:            sql.NullInt64{Int64: account1.ID, Valid: true},
+		DailyAmount:          sql.NullInt64{Int64: 100, Valid: true},
+		PerTransactionAmount: sql.NullInt64{Int64: 80, Valid: true},
+		HourlyCount:          sql.NullInt32{Int32: 2, Valid: true},
+	})
+	require.NoError(t, err)
+
+	transfer := func(amount int64) error {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		return err
+	}
+
+	var limitErr *LimitError
+
+	err = transfer(90)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, LimitPerTransaction, limitErr.Limit)
+
+	require.NoError(t, transfer(60))
+
+	// 60 + 50 is over the daily limit
+	err = transfer(50)
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, LimitDaily, limitErr.Limit)
+	require.Equal(t, int64(110), limitErr.Actual)
+
+	// rejected transfers are rolled back, so they dont count
+	require.NoError(t, transfer(40))
+
+	err = transfer(1)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, LimitDaily, limitErr.Limit)
+
+	account, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(900), account.Balance)
+}
+
+func TestTransferTxUserLimits(t *testing.T) {
+	// two accounts of the same user, a user has one account for each currency
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account1.Owner,
+		Balance:  1000,
+		Currency: util.EUR,
+	})
+	require.NoError(t, err)
+	receiver := createRandomAccountWithCurrency(t, util.USD)
+
+	// the eur transfers are converted to usd for the totals of the usd account and the other way around
+	// with the rates of the store, not the ones of the exchange_rates table
+	rates, err := fx.NewStaticRateProvider([]fx.Rate{
+		{From: util.EUR, To: util.USD, Value: "2"},
+		{From: util.USD, To: util.EUR, Value: "0.5"},
+	})
+	require.NoError(t, err)
+	store := NewStore(testDB, zerolog.Nop(), nil, rates)
+
+	_, err = testQueries.UpsertUserTransferLimit(context.Background(), UpsertUserTransferLimitParams{
+		Username:    sql.NullString{String: account1.Owner, Valid: true},
+		DailyAmount: sql.NullInt64{Int64: 200, Valid: true},
+		HourlyCount: sql.NullInt32{Int32: 3, Valid: true},
+	})
+	require.NoError(t, err)
+	_, err = testQueries.UpsertAccountTransferLimit(context.Background(), UpsertAccountTransferLimitParams{
+		AccountID:   sql.NullInt64{Int64: account2.ID, Valid: true},
+		DailyAmount: sql.NullInt64{Int64: 70, Valid: true},
+	})
+	require.NoError(t, err)
+
+	transfer := func(from Account, amount int64) error {
+		arg := TransferTxParams{
+			FromAccountID: from.ID,
+			ToAccountID:   receiver.ID,
+			Amount:        amount,
+		}
+		if from.Currency != receiver.Currency {
+			arg.ExchangeRate = fx.Rate{From: from.Currency, To: receiver.Currency, Value: "2"}
+		}
+		_, err := store.TransferTx(context.Background(), arg)
+		return err
+	}
+
+	var limitErr *LimitError
+
+	require.NoError(t, transfer(account2, 60))
+
+	// the account limit only counts the transfers of its account
+	err = transfer(account2, 20)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, LimitDaily, limitErr.Limit)
+	require.Equal(t, account2.ID, limitErr.AccountID)
+	require.Empty(t, limitErr.Username)
+
+	// 60 eur are 120 usd, 120 + 50 is under the daily limit of the user
+	require.NoError(t, transfer(account1, 50))
+
+	// the user limit counts the transfers of all accounts of the user
+	err = transfer(account1, 40)
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, LimitDaily, limitErr.Limit)
+	require.Equal(t, account1.Owner, limitErr.Username)
+	require.Equal(t, int64(210), limitErr.Actual)
+
+	// in eur the totals of the user are 65 + 50 / 2, under the daily limit, and it is the 3rd transfer of the hour
+	require.NoError(t, transfer(account2, 5))
+
+	err = transfer(account1, 1)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, LimitHourlyCount, limitErr.Limit)
+	require.Equal(t, account1.Owner, limitErr.Username)
+	require.Equal(t, int64(4), limitErr.Actual)
+}
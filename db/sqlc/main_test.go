@@ -22,7 +22,7 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal("Cannot load config:", err)
 	}
-	testDB, err = sql.Open(config.DBDriver, config.DBSource)
+	testDB, err = OpenDB(config.DBDriver, config.DBSource)
 	if err != nil {
 		log.Fatal("Cannot connect to db:", err)
 	}
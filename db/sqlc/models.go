@@ -3,7 +3,11 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -12,6 +16,15 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	// how far below zero the balance is allowed to go
+	OverdraftLimit int64 `json:"overdraft_limit"`
+	// active, frozen or closed
+	Status string `json:"status"`
+	// why the account was frozen, unfrozen or closed
+	StatusReason    string    `json:"status_reason"`
+	StatusChangedAt time.Time `json:"status_changed_at"`
+	// username of the user who froze, unfroze or closed the account, empty before the first change
+	StatusChangedBy string `json:"status_changed_by"`
 }
 
 type Entry struct {
@@ -20,6 +33,120 @@ type Entry struct {
 	// can be negative or positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// transfer that created the entry, null for other entries
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	// journal that posted the entry with the other legs, null for entries without a transfer made before journals
+	JournalID sql.NullInt64 `json:"journal_id"`
+}
+
+type ExchangeRate struct {
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+	// amount of to_currency paid for one unit of from_currency
+	Rate      string    `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Hold struct {
+	ID int64 `json:"id"`
+	// account whose funds are reserved
+	AccountID int64 `json:"account_id"`
+	// account that receives the funds when the hold is captured
+	ToAccountID    int64 `json:"to_account_id"`
+	Amount         int64 `json:"amount"`
+	CapturedAmount int64 `json:"captured_amount"`
+	// pending, captured or voided, a pending hold past expires_at is expired
+	Status string `json:"status"`
+	// transfer created by the capture
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+	// fee of the held amount, reserved on top of it since the capture charges it
+	Fee int64 `json:"fee"`
+}
+
+type IdempotencyKey struct {
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+	RequestPath    string `json:"request_path"`
+	// fingerprint of the request body the key was first used with
+	RequestHash string `json:"request_hash"`
+	// null until the request is completed
+	ResponseBody []byte    `json:"response_body"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type JournalEntry struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Quote struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Rate         string    `json:"rate"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ReconciliationRun struct {
+	ID               int64     `json:"id"`
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+	AccountsChecked  int64     `json:"accounts_checked"`
+	TransfersChecked int64     `json:"transfers_checked"`
+	// drifting accounts, orphan entries and unbalanced transfers found by the run
+	Discrepancies int64 `json:"discrepancies"`
+	// the report of the run, as printed by the reconcile command
+	Findings  json.RawMessage `json:"findings"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	Amount        int64  `json:"amount"`
+	Frequency     string `json:"frequency"`
+	// number of frequency units between two runs, e.g. 2 for every other week
+	RepeatInterval int32     `json:"repeat_interval"`
+	StartAt        time.Time `json:"start_at"`
+	// no run is scheduled after this time, null for no end
+	EndAt sql.NullTime `json:"end_at"`
+	// maximum number of runs, null for no limit
+	MaxRuns sql.NullInt32 `json:"max_runs"`
+	Status  string        `json:"status"`
+	// time of the next run or retry, null when the schedule is not active
+	NextRunAt sql.NullTime `json:"next_run_at"`
+	// number of occurrences done, including the ones skipped after failing
+	RunCount int32 `json:"run_count"`
+	// failed attempts of the current occurrence
+	FailedAttempts int32     `json:"failed_attempts"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ScheduledTransferRun struct {
+	ID           int64     `json:"id"`
+	ScheduleID   int64     `json:"schedule_id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Attempt      int32     `json:"attempt"`
+	// transfer made by the run, null when it failed
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	Error      string        `json:"error"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	RefreshToken string    `json:"refresh_token"`
+	UserAgent    string    `json:"user_agent"`
+	ClientIp     string    `json:"client_ip"`
+	IsBlocked    bool      `json:"is_blocked"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Transfer struct {
@@ -29,6 +156,43 @@ type Transfer struct {
 	// must be positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// amount credited in the currency of to account
+	ToAmount int64 `json:"to_amount"`
+	// rate used to convert amount to to_amount
+	ExchangeRate string `json:"exchange_rate"`
+	// transfer reversed by this one, null for normal transfers
+	ReversalOf sql.NullInt64 `json:"reversal_of"`
+	// part of amount sent back by the reversals of this transfer
+	ReversedAmount int64 `json:"reversed_amount"`
+	// batch the transfer was made in, null for single transfers
+	BatchID sql.NullInt64 `json:"batch_id"`
+	// charged to the from account on top of amount, in its currency
+	Fee int64 `json:"fee"`
+	// revenue account credited with the fee, null for transfers without a fee
+	FeeAccountID sql.NullInt64 `json:"fee_account_id"`
+}
+
+type TransferBatch struct {
+	ID            int64 `json:"id"`
+	FromAccountID int64 `json:"from_account_id"`
+	// failed legs are skipped instead of rolling back the whole batch
+	BestEffort bool      `json:"best_effort"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TransferLimit struct {
+	ID        int64          `json:"id"`
+	AccountID sql.NullInt64  `json:"account_id"`
+	Username  sql.NullString `json:"username"`
+	// outgoing amount in the last 24 hours, null to inherit the limit
+	DailyAmount sql.NullInt64 `json:"daily_amount"`
+	// outgoing amount in the last 30 days, null to inherit the limit
+	MonthlyAmount sql.NullInt64 `json:"monthly_amount"`
+	// maximum amount of a single transfer, null to inherit the limit
+	PerTransactionAmount sql.NullInt64 `json:"per_transaction_amount"`
+	// number of outgoing transfers in the last hour, null to inherit the limit
+	HourlyCount sql.NullInt32 `json:"hourly_count"`
+	UpdatedAt   time.Time     `json:"updated_at"`
 }
 
 type User struct {
@@ -38,4 +202,6 @@ type User struct {
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	// customer or admin, admins are promoted in the db
+	Role string `json:"role"`
 }
@@ -4,24 +4,108 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	AddTransferReversedAmount(ctx context.Context, arg AddTransferReversedAmountParams) (Transfer, error)
+	AdvanceScheduledTransfer(ctx context.Context, arg AdvanceScheduledTransferParams) (ScheduledTransfer, error)
+	BlockSession(ctx context.Context, id uuid.UUID) (Session, error)
+	CancelScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	CaptureHold(ctx context.Context, arg CaptureHoldParams) (Hold, error)
+	// schedules locked by another worker are skipped, so many workers can run side by side
+	ClaimDueScheduledTransfer(ctx context.Context, now time.Time) (ScheduledTransfer, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error)
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) error
+	CreateJournalEntry(ctx context.Context) (JournalEntry, error)
+	CreateQuote(ctx context.Context, arg CreateQuoteParams) (Quote, error)
+	CreateReconciliationRun(ctx context.Context, arg CreateReconciliationRunParams) (ReconciliationRun, error)
+	CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error)
+	CreateScheduledTransferRun(ctx context.Context, arg CreateScheduledTransferRunParams) (ScheduledTransferRun, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
+	CreateTransferBatch(ctx context.Context, arg CreateTransferBatchParams) (TransferBatch, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
-	DeleteAccount(ctx context.Context, id int64) error
 	GetAccount(ctx context.Context, id int64) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetAccountTransferLimit(ctx context.Context, accountID sql.NullInt64) (TransferLimit, error)
+	GetDefaultTransferLimit(ctx context.Context) (TransferLimit, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetExchangeRate(ctx context.Context, arg GetExchangeRateParams) (ExchangeRate, error)
+	// funds reserved by the pending holds of the account, expired holds dont reserve anything
+	GetHeldAmount(ctx context.Context, accountID int64) (int64, error)
+	GetHold(ctx context.Context, id int64) (Hold, error)
+	GetHoldForUpdate(ctx context.Context, id int64) (Hold, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetIdempotencyKeyForUpdate(ctx context.Context, arg GetIdempotencyKeyForUpdateParams) (IdempotencyKey, error)
+	GetJournalEntry(ctx context.Context, id int64) (JournalEntry, error)
+	GetLatestReconciliationRun(ctx context.Context) (ReconciliationRun, error)
+	GetLedgerCounts(ctx context.Context) (GetLedgerCountsRow, error)
+	// totals of the rolling windows of the limits, ending now
+	// reversals are refunds, they dont count against the limits of the sender
+	GetOutgoingTransferTotals(ctx context.Context, fromAccountID int64) (GetOutgoingTransferTotalsRow, error)
+	GetQuote(ctx context.Context, id uuid.UUID) (Quote, error)
+	GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferBatch(ctx context.Context, id int64) (TransferBatch, error)
+	GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	// locks the user, so the transfers of its accounts are checked against its limits one at a time
+	GetUserForUpdate(ctx context.Context, username string) (User, error)
+	GetUserTransferLimit(ctx context.Context, username sql.NullString) (TransferLimit, error)
+	// amounts are compared in the currency of the account, so incoming transfers use to_amount
+	// newest first, the next page starts before the last transfer of the previous one
+	ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]Transfer, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	// keyset pagination, the next page starts after the last account of the previous one
+	ListAccountsAfter(ctx context.Context, arg ListAccountsAfterParams) ([]Account, error)
+	// limits of the account, the ones of its owner and the system defaults, see EffectiveTransferLimits
+	ListApplicableTransferLimits(ctx context.Context, arg ListApplicableTransferLimitsParams) ([]TransferLimit, error)
+	// accounts whose balance is not the sum of their entries
+	ListBalanceDrifts(ctx context.Context) ([]ListBalanceDriftsRow, error)
+	ListBatchTransfers(ctx context.Context, batchID sql.NullInt64) ([]Transfer, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	// keyset pagination, the next page starts after the last entry of the previous one
+	ListEntriesAfter(ctx context.Context, arg ListEntriesAfterParams) ([]Entry, error)
+	ListHeldAmounts(ctx context.Context, accountIds []int64) ([]ListHeldAmountsRow, error)
+	// legs of a journal, in the order they were posted
+	ListJournalEntries(ctx context.Context, journalID sql.NullInt64) ([]Entry, error)
+	// entries that werent posted by a transfer or a journal
+	ListOrphanEntries(ctx context.Context) ([]Entry, error)
+	// totals of the user limits, one row for each currency of the accounts of the owner
+	// amounts are in the currency of the accounts, they are converted before they are summed
+	// reversals are refunds, they dont count against the limits of the sender
+	ListOwnerOutgoingTransferTotals(ctx context.Context, owner string) ([]ListOwnerOutgoingTransferTotalsRow, error)
+	ListScheduledTransferRuns(ctx context.Context, scheduleID int64) ([]ScheduledTransferRun, error)
+	ListScheduledTransfers(ctx context.Context, fromAccountID int64) ([]ScheduledTransfer, error)
+	ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error)
+	ListTransferReversals(ctx context.Context, reversalOf sql.NullInt64) ([]Transfer, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	// journals whose legs dont add up to zero in a currency
+	// the legs of a cross currency transfer dont, so the journals of a single transfer are checked by ListUnbalancedTransfers instead
+	ListUnbalancedJournals(ctx context.Context) ([]ListUnbalancedJournalsRow, error)
+	// transfers whose entries dont match them, account by account
+	// a transfer debits amount + fee from the sender, credits to_amount to the receiver and the fee to the fee account
+	ListUnbalancedTransfers(ctx context.Context) ([]ListUnbalancedTransfersRow, error)
+	SetIdempotencyKeyResponse(ctx context.Context, arg SetIdempotencyKeyResponseParams) error
+	SumEntriesSince(ctx context.Context, arg SumEntriesSinceParams) (int64, error)
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error)
+	UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error)
+	UpdateDefaultTransferLimit(ctx context.Context, arg UpdateDefaultTransferLimitParams) (TransferLimit, error)
+	// only active schedules can be changed, completed and cancelled ones are kept as they are
+	UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error)
+	UpsertAccountTransferLimit(ctx context.Context, arg UpsertAccountTransferLimitParams) (TransferLimit, error)
+	UpsertExchangeRate(ctx context.Context, arg UpsertExchangeRateParams) (ExchangeRate, error)
+	UpsertUserTransferLimit(ctx context.Context, arg UpsertUserTransferLimitParams) (TransferLimit, error)
+	VoidHold(ctx context.Context, id int64) (Hold, error)
 }
 
 var _ Querier = (*Queries)(nil)
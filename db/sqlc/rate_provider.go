@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/keremakillioglu/simplebank/fx"
+)
+
+// RateProvider serves exchange rates from the exchange_rates table
+// rates can be updated at runtime with UpsertExchangeRate
+type RateProvider struct {
+	querier Querier
+}
+
+// NewRateProvider creates a new fx.RateProvider backed by the db
+func NewRateProvider(querier Querier) fx.RateProvider {
+	return &RateProvider{querier: querier}
+}
+
+// GetRate returns the rate to convert an amount in from currency to currency
+func (provider *RateProvider) GetRate(ctx context.Context, from string, to string) (fx.Rate, error) {
+	rate, err := provider.querier.GetExchangeRate(ctx, GetExchangeRateParams{
+		FromCurrency: from,
+		ToCurrency:   to,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fx.Rate{}, fmt.Errorf("%w: %s->%s", fx.ErrRateNotFound, from, to)
+		}
+		return fx.Rate{}, err
+	}
+
+	return fx.Rate{
+		From:  rate.FromCurrency,
+		To:    rate.ToCurrency,
+		Value: rate.Rate,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Reconciliation is the report of a reconciliation of the ledger
+type Reconciliation struct {
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+	AccountsChecked  int64     `json:"accounts_checked"`
+	TransfersChecked int64     `json:"transfers_checked"`
+	// BalanceDrifts are the accounts whose balance is not the sum of their entries
+	BalanceDrifts []ListBalanceDriftsRow `json:"balance_drifts"`
+	// OrphanEntries are the entries without a transfer or a journal
+	OrphanEntries []Entry `json:"orphan_entries"`
+	// UnbalancedTransfers are the transfers whose entries dont add up to the transfer
+	UnbalancedTransfers []ListUnbalancedTransfersRow `json:"unbalanced_transfers"`
+	// UnbalancedJournals are the journals whose legs dont add up to zero, one row for each currency
+	UnbalancedJournals []ListUnbalancedJournalsRow `json:"unbalanced_journals"`
+}
+
+// Discrepancies returns the number of problems found, zero means the ledger is consistent
+func (r Reconciliation) Discrepancies() int64 {
+	return int64(len(r.BalanceDrifts) + len(r.OrphanEntries) + len(r.UnbalancedTransfers) + len(r.UnbalancedJournals))
+}
+
+// ReconcileTx checks that every balance is the sum of its entries, every transfer matches its entries
+// and the legs of every other journal add up to zero
+// accounts created with an initial balance have no entry for it, so they show up as drifting
+func (store *SQLStore) ReconcileTx(ctx context.Context) (Reconciliation, error) {
+	var result Reconciliation
+
+	// all queries see the same snapshot, so transfers committed in between dont show up as drift
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+	err := store.execTx(ctx, opts, func(q *Queries) error {
+		var err error
+		result = Reconciliation{StartedAt: time.Now()}
+
+		counts, err := q.GetLedgerCounts(ctx)
+		if err != nil {
+			return err
+		}
+		result.AccountsChecked = counts.Accounts
+		result.TransfersChecked = counts.Transfers
+
+		result.BalanceDrifts, err = q.ListBalanceDrifts(ctx)
+		if err != nil {
+			return err
+		}
+
+		result.OrphanEntries, err = q.ListOrphanEntries(ctx)
+		if err != nil {
+			return err
+		}
+
+		result.UnbalancedTransfers, err = q.ListUnbalancedTransfers(ctx)
+		if err != nil {
+			return err
+		}
+
+		result.UnbalancedJournals, err = q.ListUnbalancedJournals(ctx)
+		if err != nil {
+			return err
+		}
+
+		result.FinishedAt = time.Now()
+		return nil
+	})
+
+	return result, err
+}
+
+// RecordReconciliation writes the report to the reconciliation_runs table
+func (store *SQLStore) RecordReconciliation(ctx context.Context, report Reconciliation) (ReconciliationRun, error) {
+	findings, err := json.Marshal(report)
+	if err != nil {
+		return ReconciliationRun{}, err
+	}
+
+	return store.CreateReconciliationRun(ctx, CreateReconciliationRunParams{
+		StartedAt:        report.StartedAt,
+		FinishedAt:       report.FinishedAt,
+		AccountsChecked:  report.AccountsChecked,
+		TransfersChecked: report.TransfersChecked,
+		Discrepancies:    report.Discrepancies(),
+		Findings:         findings,
+	})
+}
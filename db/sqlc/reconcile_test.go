@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	// the funded balance has no entry, so account1 drifts by it
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	report, err := store.ReconcileTx(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.AccountsChecked >= 2)
+	require.True(t, report.TransfersChecked >= 1)
+
+	drifts := make(map[int64]ListBalanceDriftsRow)
+	for _, drift := range report.BalanceDrifts {
+		drifts[drift.AccountID] = drift
+	}
+	require.Equal(t, ListBalanceDriftsRow{AccountID: account1.ID, Balance: 900, EntriesTotal: -100, Drift: 1000}, drifts[account1.ID])
+	require.NotContains(t, drifts, account2.ID)
+
+	for _, transfer := range report.UnbalancedTransfers {
+		require.NotEqual(t, result.Transfer.ID, transfer.TransferID)
+	}
+
+	// an entry without a transfer, and an extra entry of the transfer
+	orphan, err := testQueries.CreateEntry(context.Background(), CreateEntryParams{
+		AccountID: account2.ID,
+		Amount:    5,
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.CreateEntry(context.Background(), CreateEntryParams{
+		AccountID:  account1.ID,
+		Amount:     -5,
+		TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	report, err = store.ReconcileTx(context.Background())
+	require.NoError(t, err)
+
+	drifts = make(map[int64]ListBalanceDriftsRow)
+	for _, drift := range report.BalanceDrifts {
+		drifts[drift.AccountID] = drift
+	}
+	require.Equal(t, int64(-5), drifts[account2.ID].Drift)
+
+	var orphanIDs []int64
+	for _, entry := range report.OrphanEntries {
+		orphanIDs = append(orphanIDs, entry.ID)
+	}
+	require.Contains(t, orphanIDs, orphan.ID)
+
+	require.Contains(t, report.UnbalancedTransfers, ListUnbalancedTransfersRow{
+		TransferID:      result.Transfer.ID,
+		ExpectedEntries: 2,
+		ActualEntries:   3,
+	})
+
+	run, err := store.RecordReconciliation(context.Background(), report)
+	require.NoError(t, err)
+	require.Equal(t, report.Discrepancies(), run.Discrepancies)
+	require.True(t, run.Discrepancies >= 3)
+
+	var findings Reconciliation
+	err = json.Unmarshal(run.Findings, &findings)
+	require.NoError(t, err)
+	require.Len(t, findings.UnbalancedTransfers, len(report.UnbalancedTransfers))
+}
+
+func TestReconcileTxWithFee(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	feeAccount := createRandomAccountWithCurrency(t, util.USD)
+	store := newFeeStore(t, feeAccount, 3)
+
+	// a transfer with a fee has four entries
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	report, err := store.ReconcileTx(context.Background())
+	require.NoError(t, err)
+
+	for _, transfer := range report.UnbalancedTransfers {
+		require.NotEqual(t, result.Transfer.ID, transfer.TransferID)
+	}
+}
+
+func TestReconcileTxWithJournal(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+	account2 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+
+	// a journal without a transfer, its entries are not orphans
+	result, err := store.PostJournalTx(context.Background(), []Leg{
+		{AccountID: account1.ID, Amount: -30, Currency: util.USD},
+		{AccountID: account2.ID, Amount: 30, Currency: util.USD},
+	})
+	require.NoError(t, err)
+	journalID := sql.NullInt64{Int64: result.Journal.ID, Valid: true}
+
+	report, err := store.ReconcileTx(context.Background())
+	require.NoError(t, err)
+
+	for _, entry := range report.OrphanEntries {
+		require.NotEqual(t, journalID, entry.JournalID)
+	}
+	for _, journal := range report.UnbalancedJournals {
+		require.NotEqual(t, result.Journal.ID, journal.JournalID)
+	}
+
+	// an extra leg breaks the balance of the journal
+	_, err = testQueries.CreateEntry(context.Background(), CreateEntryParams{
+		AccountID: account2.ID,
+		Amount:    5,
+		JournalID: journalID,
+	})
+	require.NoError(t, err)
+
+	report, err = store.ReconcileTx(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, report.UnbalancedJournals, ListUnbalancedJournalsRow{
+		JournalID: result.Journal.ID,
+		Currency:  util.USD,
+		Total:     5,
+	})
+
+	run, err := store.RecordReconciliation(context.Background(), report)
+	require.NoError(t, err)
+	require.Equal(t, report.Discrepancies(), run.Discrepancies)
+
+	var findings Reconciliation
+	err = json.Unmarshal(run.Findings, &findings)
+	require.NoError(t, err)
+	require.Len(t, findings.UnbalancedJournals, len(report.UnbalancedJournals))
+}
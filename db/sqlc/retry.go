@@ -0,0 +1,91 @@
+package db
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// postgres error codes of the transaction failures that are safe to retry
+// the transaction is rolled back as a whole, so running it again from the beginning is fine
+const (
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+)
+
+// TxRetryPolicy controls how execTx retries transactions that failed because of
+// a serialization failure or a deadlock
+type TxRetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt, 0 disables retrying
+	MaxRetries int
+	// BaseDelay is the upper bound of the wait before the first retry, it doubles on every retry
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential growth of the delay
+	MaxDelay time.Duration
+}
+
+// DefaultTxRetryPolicy is used by the stores created with NewStore
+var DefaultTxRetryPolicy = TxRetryPolicy{
+	MaxRetries: 5,
+	BaseDelay:  10 * time.Millisecond,
+	MaxDelay:   500 * time.Millisecond,
+}
+
+// TxStats contains counters of the transactions run by a store, for observability
+type TxStats struct {
+	// Retries is the total number of times a transaction was run again
+	Retries int64 `json:"retries"`
+	// RetriesExhausted is the number of transactions that still failed after MaxRetries
+	RetriesExhausted int64 `json:"retries_exhausted"`
+}
+
+// txCounters is updated concurrently by all transactions of a store
+type txCounters struct {
+	retries          int64
+	retriesExhausted int64
+}
+
+func (c *txCounters) snapshot() TxStats {
+	return TxStats{
+		Retries:          atomic.LoadInt64(&c.retries),
+		RetriesExhausted: atomic.LoadInt64(&c.retriesExhausted),
+	}
+}
+
+// isRetryableError reports whether the transaction failed because of a serialization failure or a deadlock
+func isRetryableError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailureCode || pqErr.Code == deadlockDetectedCode
+}
+
+// *rand.Rand is not safe for concurrent use, so access is guarded by a mutex
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// backoff returns a random delay ("full jitter") for the given retry, starting from 0
+// random delays keep the conflicting transactions from running into each other again at the same time
+func (policy TxRetryPolicy) backoff(retry int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 0; i < retry && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(delay) + 1))
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/logging"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	require.True(t, isRetryableError(&pq.Error{Code: serializationFailureCode}))
+	require.True(t, isRetryableError(&pq.Error{Code: deadlockDetectedCode}))
+	// wrapped errors are detected as well
+	require.True(t, isRetryableError(fmt.Errorf("tx err: %w", &pq.Error{Code: deadlockDetectedCode})))
+
+	require.False(t, isRetryableError(&pq.Error{Code: "23505"}))
+	require.False(t, isRetryableError(errors.New("some error")))
+	require.False(t, isRetryableError(nil))
+}
+
+func TestTxRetryPolicyBackoff(t *testing.T) {
+	policy := TxRetryPolicy{
+		MaxRetries: 10,
+		BaseDelay:  10 * time.Millisecond,
+		MaxDelay:   50 * time.Millisecond,
+	}
+
+	for retry := 0; retry < policy.MaxRetries; retry++ {
+		delay := policy.backoff(retry)
+		require.True(t, delay >= 0)
+		require.True(t, delay <= policy.MaxDelay)
+	}
+
+	// first retry waits at most the base delay
+	for i := 0; i < 100; i++ {
+		require.True(t, policy.backoff(0) <= policy.BaseDelay)
+	}
+}
+
+func TestExecTxRetry(t *testing.T) {
+	var logs bytes.Buffer
+	store := &SQLStore{
+		db:      testDB,
+		Queries: New(testDB),
+		retryPolicy: TxRetryPolicy{
+			MaxRetries: 3,
+			BaseDelay:  time.Millisecond,
+			MaxDelay:   5 * time.Millisecond,
+		},
+		logger: zerolog.New(&logs),
+	}
+
+	// fails twice with a serialization failure and then succeeds
+	calls := 0
+	ctx := logging.WithRequestID(context.Background(), "test-request")
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		calls++
+		if calls <= 2 {
+			return &pq.Error{Code: serializationFailureCode}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	require.Equal(t, TxStats{Retries: 2}, store.TxStats())
+
+	// the retries are logged with the request id of the ctx
+	require.Equal(t, 2, bytes.Count(logs.Bytes(), []byte(`"request_id":"test-request"`)))
+	require.Contains(t, logs.String(), "retrying transaction")
+
+	// other errors are not retried
+	calls = 0
+	err = store.execTx(context.Background(), nil, func(q *Queries) error {
+		calls++
+		return errors.New("some error")
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+
+	// retries are bounded
+	calls = 0
+	err = store.execTx(context.Background(), nil, func(q *Queries) error {
+		calls++
+		return &pq.Error{Code: deadlockDetectedCode}
+	})
+	require.True(t, isRetryableError(err))
+	require.Equal(t, store.retryPolicy.MaxRetries+1, calls)
+	require.Equal(t, TxStats{Retries: 5, RetriesExhausted: 1}, store.TxStats())
+}
+
+func TestExecTxRetryContextCanceled(t *testing.T) {
+	store := &SQLStore{
+		db:      testDB,
+		Queries: New(testDB),
+		retryPolicy: TxRetryPolicy{
+			MaxRetries: 3,
+			BaseDelay:  time.Hour,
+			MaxDelay:   time.Hour,
+		},
+		logger: zerolog.Nop(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		calls++
+		// client goes away while the first attempt is running
+		cancel()
+		return &pq.Error{Code: serializationFailureCode}
+	})
+	require.True(t, isRetryableError(err))
+	require.Equal(t, 1, calls)
+}
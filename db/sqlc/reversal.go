@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/keremakillioglu/simplebank/fx"
+)
+
+// Different types of error returned by ReverseTransferTx
+var (
+	// ErrTransferAlreadyReversed is returned when the whole amount of the transfer was already sent back
+	ErrTransferAlreadyReversed = errors.New("transfer is already reversed")
+	// ErrReversalExceedsTransfer is returned when more than the remaining amount of the transfer is reversed
+	ErrReversalExceedsTransfer = errors.New("reversal amount exceeds the remaining amount of the transfer")
+	// ErrReversalOfReversal is returned when a reversal itself is reversed, the original transfer should be made again instead
+	ErrReversalOfReversal = errors.New("reversal cannot be reversed")
+)
+
+// ReverseTransferTxParams contains the input parameters of the reverse transfer transaction
+type ReverseTransferTxParams struct {
+	TransferID int64 `json:"transfer_id"`
+	// Amount is in the currency of the from account of the original transfer
+	// the remaining amount of the transfer is reversed when it is zero
+	Amount int64 `json:"amount"`
+}
+
+// ReverseTransferTxResult is the result of the reverse transfer transaction
+type ReverseTransferTxResult struct {
+	// Original is the reversed transfer, with its updated reversed amount
+	Original Transfer         `json:"original"`
+	Reversal TransferTxResult `json:"reversal"`
+}
+
+// ReverseTransferTx sends the money of a transfer back with a linked reversal transfer and opposite entries
+// a transfer can be reversed partially more than once, until its whole amount is sent back
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error) {
+	var result ReverseTransferTxResult
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		// lock the original transfer, so concurrent reversals cannot send back more than its amount
+		original, err := q.GetTransferForUpdate(ctx, arg.TransferID)
+		if err != nil {
+			return err
+		}
+
+		if original.ReversalOf.Valid {
+			return fmt.Errorf("transfer [%d]: %w", original.ID, ErrReversalOfReversal)
+		}
+
+		remaining := original.Amount - original.ReversedAmount
+		if remaining == 0 {
+			return fmt.Errorf("transfer [%d]: %w", original.ID, ErrTransferAlreadyReversed)
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount > remaining {
+			return fmt.Errorf("transfer [%d] has %d remaining: %w", original.ID, remaining, ErrReversalExceedsTransfer)
+		}
+
+		toAmount, exchangeRate, err := reversalAmount(original, amount)
+		if err != nil {
+			return err
+		}
+
+		// the receiver of the original transfer sends the money back, so its balance is checked like a normal transfer
+		// no fee is charged for sending back the money
+		result.Reversal, err = postTransfer(ctx, q, nil, CreateTransferParams{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        toAmount,
+			ToAmount:      amount,
+			ExchangeRate:  exchangeRate,
+			ReversalOf:    sql.NullInt64{Int64: original.ID, Valid: true},
+		}, 0)
+		if err != nil {
+			return err
+		}
+
+		result.Original, err = q.AddTransferReversedAmount(ctx, AddTransferReversedAmountParams{
+			ID:     original.ID,
+			Amount: amount,
+		})
+		return err
+	})
+	if err == nil {
+		countTransfer(result.Reversal)
+	}
+
+	return result, err
+}
+
+// reversalAmount returns the part of the to_amount of the original transfer that is sent back with amount,
+// and the rate of the reversal
+// the parts are taken from the total reversed amount, so the reversals of a transfer add up to its to_amount exactly
+func reversalAmount(original Transfer, amount int64) (int64, string, error) {
+	toAmount := toAmountShare(original, original.ReversedAmount+amount) - toAmountShare(original, original.ReversedAmount)
+	if toAmount == 0 {
+		return 0, "", fx.ErrAmountTooSmall
+	}
+
+	rate, err := fx.Rate{Value: original.ExchangeRate}.Invert()
+	if err != nil {
+		return 0, "", err
+	}
+
+	return toAmount, rate.Value, nil
+}
+
+// toAmountShare returns the part of to_amount that belongs to the given part of amount, rounded down
+// big.Int is used since the product can overflow int64
+func toAmountShare(transfer Transfer, amount int64) int64 {
+	share := new(big.Int).Mul(big.NewInt(transfer.ToAmount), big.NewInt(amount))
+	return share.Quo(share, big.NewInt(transfer.Amount)).Int64()
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseTransferTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	// partial reversal
+	result, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Amount:     30,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), result.Original.ReversedAmount)
+
+	reversal := result.Reversal
+	require.Equal(t, transfer.Transfer.ID, reversal.Transfer.ReversalOf.Int64)
+	require.Equal(t, account2.ID, reversal.Transfer.FromAccountID)
+	require.Equal(t, account1.ID, reversal.Transfer.ToAccountID)
+	require.Equal(t, int64(-30), reversal.FromEntry.Amount)
+	require.Equal(t, int64(30), reversal.ToEntry.Amount)
+	require.Equal(t, int64(30), reversal.ToAccount.Balance)
+	require.Equal(t, int64(70), reversal.FromAccount.Balance)
+
+	// more than the remaining amount
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Amount:     71,
+	})
+	require.True(t, errors.Is(err, ErrReversalExceedsTransfer))
+
+	// rest of the transfer
+	result, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(100), result.Original.ReversedAmount)
+	require.Equal(t, int64(70), result.Reversal.Transfer.Amount)
+	require.Equal(t, int64(100), result.Reversal.ToAccount.Balance)
+	require.Zero(t, result.Reversal.FromAccount.Balance)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.True(t, errors.Is(err, ErrTransferAlreadyReversed))
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: result.Reversal.Transfer.ID})
+	require.True(t, errors.Is(err, ErrReversalOfReversal))
+
+	reversals, err := testQueries.ListTransferReversals(context.Background(), result.Reversal.Transfer.ReversalOf)
+	require.NoError(t, err)
+	require.Len(t, reversals, 2)
+}
+
+func TestReverseTransferTxCrossCurrency(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountWithCurrency(t, util.EUR), 0)
+
+	// 101 USD cents are 93 EUR cents
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        101,
+		ExchangeRate:  fx.Rate{From: util.USD, To: util.EUR, Value: "0.92"},
+	})
+	require.NoError(t, err)
+
+	result, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Amount:     50,
+	})
+	require.NoError(t, err)
+	// 93 * 50 / 101 rounded down
+	require.Equal(t, int64(46), result.Reversal.Transfer.Amount)
+	require.Equal(t, int64(50), result.Reversal.Transfer.ToAmount)
+
+	// the reversals add up to the original amounts, so nothing is left on the account after the last one
+	result, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(47), result.Reversal.Transfer.Amount)
+	require.Zero(t, result.Reversal.FromAccount.Balance)
+	require.Equal(t, int64(1000), result.Reversal.ToAccount.Balance)
+}
+
+func TestReverseTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	// receiver has already spent the money
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	// the reversal is rolled back
+	original, err := testQueries.GetTransfer(context.Background(), transfer.Transfer.ID)
+	require.NoError(t, err)
+	require.Zero(t, original.ReversedAmount)
+}
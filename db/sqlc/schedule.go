@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/util"
+)
+
+// Statuses of a scheduled transfer
+const (
+	ScheduleStatusActive    = "active"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusCancelled = "cancelled"
+)
+
+// Recurrence returns the run times of the schedule
+func (schedule ScheduledTransfer) Recurrence() util.Recurrence {
+	recurrence := util.Recurrence{
+		Frequency: schedule.Frequency,
+		Interval:  int(schedule.RepeatInterval),
+		Start:     schedule.StartAt,
+	}
+	if schedule.EndAt.Valid {
+		recurrence.Until = schedule.EndAt.Time
+	}
+	if schedule.MaxRuns.Valid {
+		recurrence.Count = int(schedule.MaxRuns.Int32)
+	}
+	return recurrence
+}
+
+// RunScheduledTransferTxParams contains the input parameters of the scheduled transfer transaction
+type RunScheduledTransferTxParams struct {
+	// schedules with a next run until Now are due
+	Now time.Time `json:"now"`
+	// a failed run is retried after RetryInterval, until the occurrence fails MaxAttempts times and is skipped
+	RetryInterval time.Duration `json:"retry_interval"`
+	MaxAttempts   int32         `json:"max_attempts"`
+}
+
+// RunScheduledTransferTxResult is the result of the scheduled transfer transaction
+type RunScheduledTransferTxResult struct {
+	// Schedule is updated for its next run
+	Schedule ScheduledTransfer `json:"schedule"`
+	// Run is empty when the schedule has ended before its due run, nothing is transferred in that case
+	Run      ScheduledTransferRun `json:"run"`
+	Transfer *TransferTxResult    `json:"transfer,omitempty"`
+}
+
+// RunScheduledTransferTx claims a single due schedule, makes its transfer and records the run
+// sql.ErrNoRows is returned when no schedule is due
+// the schedule stays locked until the end of the tx, so it cannot run twice, even with many workers
+func (store *SQLStore) RunScheduledTransferTx(ctx context.Context, arg RunScheduledTransferTxParams) (RunScheduledTransferTxResult, error) {
+	var result RunScheduledTransferTxResult
+
+	err := store.execTx(ctx, nil, func(q *Queries) error {
+		// reset the result of a previous attempt, the tx might be retried
+		result = RunScheduledTransferTxResult{}
+
+		schedule, err := q.ClaimDueScheduledTransfer(ctx, arg.Now)
+		if err != nil {
+			return err
+		}
+
+		recurrence := schedule.Recurrence()
+
+		// end_at or max_runs might have been changed since the run was scheduled
+		scheduledFor, ok := recurrence.Next(int(schedule.RunCount))
+		if !ok {
+			result.Schedule, err = q.AdvanceScheduledTransfer(ctx, AdvanceScheduledTransferParams{
+				ID:       schedule.ID,
+				Status:   ScheduleStatusCompleted,
+				RunCount: schedule.RunCount,
+			})
+			return err
+		}
+
+		// a failed transfer rolls back to the savepoint, so the failure can still be recorded in this tx
+		var transfer TransferTxResult
+		transferErr := savepoint(ctx, q, "scheduled_transfer", func() error {
+			var err error
+			transfer, err = transferTx(ctx, q, store.fees, store.rates, TransferTxParams{
+				FromAccountID: schedule.FromAccountID,
+				ToAccountID:   schedule.ToAccountID,
+				Amount:        schedule.Amount,
+			})
+			return err
+		})
+		if transferErr != nil && (isRetryableError(transferErr) || errors.Is(transferErr, errSavepoint)) {
+			return transferErr
+		}
+
+		run := CreateScheduledTransferRunParams{
+			ScheduleID:   schedule.ID,
+			ScheduledFor: scheduledFor,
+			Attempt:      schedule.FailedAttempts + 1,
+		}
+		if transferErr != nil {
+			run.Error = transferErr.Error()
+		} else {
+			run.TransferID = sql.NullInt64{Int64: transfer.Transfer.ID, Valid: true}
+			result.Transfer = &transfer
+		}
+
+		result.Run, err = q.CreateScheduledTransferRun(ctx, run)
+		if err != nil {
+			return err
+		}
+
+		result.Schedule, err = q.AdvanceScheduledTransfer(ctx, nextScheduleState(schedule, recurrence, arg, transferErr))
+		return err
+	})
+	if err == nil && result.Transfer != nil {
+		countTransfer(*result.Transfer)
+	}
+
+	return result, err
+}
+
+// nextScheduleState returns the state of the schedule after a run
+func nextScheduleState(schedule ScheduledTransfer, recurrence util.Recurrence, arg RunScheduledTransferTxParams, transferErr error) AdvanceScheduledTransferParams {
+	next := AdvanceScheduledTransferParams{
+		ID:             schedule.ID,
+		Status:         ScheduleStatusActive,
+		RunCount:       schedule.RunCount,
+		FailedAttempts: schedule.FailedAttempts,
+	}
+
+	// a closed account never becomes active again, so there is no point in running the schedule anymore
+	if errors.Is(transferErr, ErrAccountClosed) {
+		next.Status = ScheduleStatusCancelled
+		return next
+	}
+
+	if transferErr != nil && schedule.FailedAttempts+1 < arg.MaxAttempts {
+		next.FailedAttempts++
+		next.NextRunAt = sql.NullTime{Time: arg.Now.Add(arg.RetryInterval), Valid: true}
+		return next
+	}
+
+	// the occurrence is done, either it succeeded or it failed too many times
+	next.RunCount++
+	next.FailedAttempts = 0
+
+	nextRun, ok := recurrence.Next(int(next.RunCount))
+	if !ok {
+		next.Status = ScheduleStatusCompleted
+		return next
+	}
+	next.NextRunAt = sql.NullTime{Time: nextRun, Valid: true}
+	return next
+}
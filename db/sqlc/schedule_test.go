@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// createDueSchedule creates a schedule that is already due, the tests run it to completion so no due schedule is left behind
+func createDueSchedule(t *testing.T, from Account, to Account, amount int64, count int32) ScheduledTransfer {
+	startAt := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	schedule, err := testQueries.CreateScheduledTransfer(context.Background(), CreateScheduledTransferParams{
+		FromAccountID:  from.ID,
+		ToAccountID:    to.ID,
+		Amount:         amount,
+		Frequency:      util.FrequencyDaily,
+		RepeatInterval: 1,
+		StartAt:        startAt,
+		MaxRuns:        sql.NullInt32{Int32: count, Valid: true},
+		NextRunAt:      sql.NullTime{Time: startAt, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ScheduleStatusActive, schedule.Status)
+	return schedule
+}
+
+func TestRunScheduledTransferTx(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	schedule := createDueSchedule(t, account1, account2, 10, 2)
+
+	arg := RunScheduledTransferTxParams{
+		Now:           time.Now(),
+		RetryInterval: time.Hour,
+		MaxAttempts:   3,
+	}
+
+	result, err := store.RunScheduledTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, schedule.ID, result.Schedule.ID)
+	require.NotNil(t, result.Transfer)
+	require.Equal(t, result.Transfer.Transfer.ID, result.Run.TransferID.Int64)
+	require.Equal(t, schedule.StartAt, result.Run.ScheduledFor.UTC())
+
+	require.Equal(t, ScheduleStatusActive, result.Schedule.Status)
+	require.Equal(t, int32(1), result.Schedule.RunCount)
+	require.Equal(t, schedule.StartAt.AddDate(0, 0, 1), result.Schedule.NextRunAt.Time.UTC())
+
+	// second and last run completes the schedule
+	result, err = store.RunScheduledTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, schedule.ID, result.Schedule.ID)
+	require.Equal(t, ScheduleStatusCompleted, result.Schedule.Status)
+	require.False(t, result.Schedule.NextRunAt.Valid)
+
+	account, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(80), account.Balance)
+
+	runs, err := testQueries.ListScheduledTransferRuns(context.Background(), schedule.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+}
+
+func TestRunScheduledTransferTxWithFee(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	feeAccount := createRandomAccountWithCurrency(t, util.USD)
+	store := newFeeStore(t, feeAccount, 5)
+	schedule := createDueSchedule(t, account1, account2, 10, 1)
+
+	// a scheduled transfer is charged the fee like the one made with the api
+	result, err := store.RunScheduledTransferTx(context.Background(), RunScheduledTransferTxParams{
+		Now:           time.Now(),
+		RetryInterval: time.Hour,
+		MaxAttempts:   3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, schedule.ID, result.Schedule.ID)
+	require.NotNil(t, result.Transfer)
+	require.Equal(t, int64(5), result.Transfer.Transfer.Fee)
+	require.Equal(t, feeAccount.ID, result.Transfer.Transfer.FeeAccountID.Int64)
+	require.NotNil(t, result.Transfer.FeeEntry)
+	require.Equal(t, int64(85), result.Transfer.FromAccount.Balance)
+
+	updatedFeeAccount, err := testQueries.GetAccount(context.Background(), feeAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, feeAccount.Balance+5, updatedFeeAccount.Balance)
+}
+
+func TestRunScheduledTransferTxRetry(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 0)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	schedule := createDueSchedule(t, account1, account2, 10, 1)
+
+	now := time.Now()
+	arg := RunScheduledTransferTxParams{
+		Now:           now,
+		RetryInterval: time.Hour,
+		MaxAttempts:   2,
+	}
+
+	// first failure is retried after the retry interval
+	result, err := store.RunScheduledTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, schedule.ID, result.Schedule.ID)
+	require.Nil(t, result.Transfer)
+	require.False(t, result.Run.TransferID.Valid)
+	require.NotEmpty(t, result.Run.Error)
+	require.Equal(t, int32(1), result.Schedule.FailedAttempts)
+	require.Zero(t, result.Schedule.RunCount)
+	require.WithinDuration(t, now.Add(time.Hour), result.Schedule.NextRunAt.Time, time.Second)
+
+	// the retry is not due yet
+	_, err = store.RunScheduledTransferTx(context.Background(), arg)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	// last attempt fails as well, so the occurrence is skipped, and it was the only one
+	arg.Now = now.Add(2 * time.Hour)
+	result, err = store.RunScheduledTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, schedule.ID, result.Schedule.ID)
+	require.Equal(t, int32(2), result.Run.Attempt)
+	require.Equal(t, ScheduleStatusCompleted, result.Schedule.Status)
+	require.Equal(t, int32(1), result.Schedule.RunCount)
+	require.Zero(t, result.Schedule.FailedAttempts)
+}
+
+func TestCancelScheduledTransfer(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	schedule := createDueSchedule(t, account1, account2, 10, 1)
+
+	cancelled, err := testQueries.CancelScheduledTransfer(context.Background(), schedule.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduleStatusCancelled, cancelled.Status)
+	require.False(t, cancelled.NextRunAt.Valid)
+
+	// only active schedules can be cancelled or updated
+	_, err = testQueries.CancelScheduledTransfer(context.Background(), schedule.ID)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	_, err = testQueries.UpdateScheduledTransfer(context.Background(), UpdateScheduledTransferParams{
+		ID:     schedule.ID,
+		Amount: 20,
+	})
+	require.Equal(t, sql.ErrNoRows, err)
+}
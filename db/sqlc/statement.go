@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// StatementTxParams contains the input parameters of the statement transaction
+type StatementTxParams struct {
+	AccountID int64 `json:"account_id"`
+	// entries created in [From, To) are listed
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// StatementLine is an entry of the statement with the balance of the account after it
+type StatementLine struct {
+	EntryID    int64         `json:"entry_id"`
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	// other account of the transfer, null if the entry wasnt created by a transfer
+	CounterpartyAccountID sql.NullInt64 `json:"counterparty_account_id"`
+	// the entry is the fee of the transfer, not its amount
+	Fee       bool      `json:"fee"`
+	Amount    int64     `json:"amount"`
+	Balance   int64     `json:"balance"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StatementTxResult is the result of the statement transaction
+type StatementTxResult struct {
+	Account        Account         `json:"account"`
+	OpeningBalance int64           `json:"opening_balance"`
+	ClosingBalance int64           `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+}
+
+// StatementTx lists the entries of an account in a time range with running balances
+// accounts can be created with an initial balance that has no entry,
+// so the opening balance is calculated backwards from the current balance
+func (store *SQLStore) StatementTx(ctx context.Context, arg StatementTxParams) (StatementTxResult, error) {
+	var result StatementTxResult
+
+	// all queries see the same snapshot, so transfers committed in between dont change the balances
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+	err := store.execTx(ctx, opts, func(q *Queries) error {
+		var err error
+		result = StatementTxResult{}
+
+		result.Account, err = q.GetAccount(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		sinceFrom, err := q.SumEntriesSince(ctx, SumEntriesSinceParams{
+			AccountID: arg.AccountID,
+			CreatedAt: arg.From,
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err := q.ListStatementEntries(ctx, ListStatementEntriesParams{
+			AccountID: arg.AccountID,
+			FromTime:  arg.From,
+			ToTime:    arg.To,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.OpeningBalance = result.Account.Balance - sinceFrom
+		balance := result.OpeningBalance
+		result.Lines = make([]StatementLine, 0, len(rows))
+
+		for _, row := range rows {
+			balance += row.Amount
+			result.Lines = append(result.Lines, StatementLine{
+				EntryID:               row.ID,
+				TransferID:            row.TransferID,
+				CounterpartyAccountID: counterparty(row),
+				Fee:                   row.IsFee,
+				Amount:                row.Amount,
+				Balance:               balance,
+				CreatedAt:             row.CreatedAt,
+			})
+		}
+
+		result.ClosingBalance = balance
+		return nil
+	})
+
+	return result, err
+}
+
+// counterparty returns the other account of the transfer of the entry,
+// for a fee entry thats the fee account of the sender and the sender of the fee account
+func counterparty(row ListStatementEntriesRow) sql.NullInt64 {
+	if !row.TransferID.Valid {
+		return sql.NullInt64{}
+	}
+	if row.IsFee {
+		if row.FromAccountID.Int64 == row.AccountID {
+			return row.FeeAccountID
+		}
+		return row.FromAccountID
+	}
+	if row.FromAccountID.Int64 == row.AccountID {
+		return row.ToAccountID
+	}
+	return row.FromAccountID
+}
@@ -3,13 +3,53 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/fee"
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/logging"
+	"github.com/keremakillioglu/simplebank/metrics"
+	"github.com/keremakillioglu/simplebank/tracing"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Different types of error returned by TransferTx, the whole transaction is rolled back in these cases
+var (
+	// ErrInsufficientFunds is returned when the sender's balance (plus its overdraft limit) is not enough to cover the amount
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrCurrencyMismatch is returned when the exchange rate doesnt match the currencies of the accounts
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrAccountFrozen is returned when the sender or the receiver account is frozen
+	ErrAccountFrozen = errors.New("account is frozen")
+	// ErrAccountClosed is returned when the sender or the receiver account is closed
+	ErrAccountClosed = errors.New("account is closed")
 )
 
 // Store provides all functions to execute db queries and transaction
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	PostJournalTx(ctx context.Context, legs []Leg) (JournalTxResult, error)
+	TxStats() TxStats
+	IdempotentTransferTx(ctx context.Context, idem IdempotencyParams, arg TransferTxParams) (IdempotentTxResult, error)
+	IdempotentCreateAccountTx(ctx context.Context, idem IdempotencyParams, arg CreateAccountParams) (IdempotentTxResult, error)
+	StatementTx(ctx context.Context, arg StatementTxParams) (StatementTxResult, error)
+	ChangeAccountStatusTx(ctx context.Context, arg ChangeAccountStatusTxParams) (Account, error)
+	AuthorizeTx(ctx context.Context, arg AuthorizeTxParams) (Hold, error)
+	CaptureTx(ctx context.Context, arg CaptureTxParams) (CaptureTxResult, error)
+	VoidTx(ctx context.Context, holdID int64) (Hold, error)
+	ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error)
+	BatchTransferTx(ctx context.Context, arg BatchTransferTxParams) (BatchTransferTxResult, error)
+	RunScheduledTransferTx(ctx context.Context, arg RunScheduledTransferTxParams) (RunScheduledTransferTxResult, error)
+	ReconcileTx(ctx context.Context) (Reconciliation, error)
+	RecordReconciliation(ctx context.Context, report Reconciliation) (ReconciliationRun, error)
+	Ping(ctx context.Context) error
+	GetSchemaVersion(ctx context.Context) (SchemaVersion, error)
 }
 
 // SQLStore provides all functions to execute and run SQL queries in transactions
@@ -23,31 +63,136 @@ type SQLStore struct {
 	*Queries
 	//  sql.DB object is used because its required to create a new db transaction
 	db *sql.DB
+	// failed transactions are retried according to this policy, see execTx
+	retryPolicy TxRetryPolicy
+	counters    txCounters
+	// the lines are tagged with the request id of the ctx of the transaction, see logging.ForContext
+	logger zerolog.Logger
+	// fees charged on top of the transfers, see postTransfer
+	fees FeeQuoter
+	// rates the user limits convert the totals of the other currencies with, see ownerOutgoingTransferTotals
+	rates fx.RateProvider
+}
+
+// FeeQuoter returns the fee of a transfer in the currency of the from account, fee.Schedule implements it
+type FeeQuoter interface {
+	Quote(currency string, amount int64) (fee.Quote, error)
 }
 
 //NewStore creates a new store
-func NewStore(db *sql.DB) Store {
+// fees can be nil, no fees are charged then
+// rates must be the provider the transfers are made with, the exchange_rates table is used when it is nil
+func NewStore(db *sql.DB, logger zerolog.Logger, fees FeeQuoter, rates fx.RateProvider) Store {
+	queries := New(&tracedDB{db: db}) //defined in db.go by sqlc
+	if rates == nil {
+		rates = NewRateProvider(queries)
+	}
 
 	return &SQLStore{
-		db:      db,
-		Queries: New(db), //defined in db.go by sqlc
+		db:          db,
+		Queries:     queries,
+		retryPolicy: DefaultTxRetryPolicy,
+		logger:      logger,
+		fees:        fees,
+		rates:       rates,
 	}
 }
 
+// TxStats returns the retry counters of the transactions run by the store
+func (store *SQLStore) TxStats() TxStats {
+	return store.counters.snapshot()
+}
+
 // take a context and callback function as an input, start a new db transaction
 // create a new Queries object with that transaction, and call the callback function on the queries
 // finally commit or rollback the transaction
-func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
-	tx, err := store.db.BeginTx(ctx, nil)
+// opts can be nil to use the default isolation level of the db
+// transactions failing with a serialization failure or a deadlock are run again with a jittered backoff,
+// so fn must be safe to call more than once
+// every attempt has its own span in the span of the transaction, the queries of fn are the children of the attempt
+func (store *SQLStore) execTx(ctx context.Context, opts *sql.TxOptions, fn func(*Queries) error) (err error) {
+	defer observeTx(time.Now(), &err)
+	logger := logging.ForContext(ctx, store.logger)
+
+	ctx, span := tracing.Start(ctx, "execTx", trace.WithAttributes(txAttributes(opts)...))
+	attempts := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("db.tx.attempts", attempts))
+		tracing.End(span, err)
+	}()
+
+	for retry := 0; ; retry++ {
+		attempts++
+		err = store.runTx(ctx, opts, fn, attempts)
+		if err == nil || !isRetryableError(err) {
+			return err
+		}
+
+		if retry >= store.retryPolicy.MaxRetries {
+			atomic.AddInt64(&store.counters.retriesExhausted, 1)
+			metrics.TxRetriesExhausted.Inc()
+			logger.Error().Err(err).Int("attempts", retry+1).Msg("transaction retries exhausted")
+			span.SetAttributes(attribute.Bool("db.tx.retries_exhausted", true))
+			return err
+		}
+
+		// dont wait for the next attempt if the client is already gone
+		backoff := store.retryPolicy.backoff(retry)
+		logger.Warn().Err(err).Int("attempt", retry+1).Dur("backoff", backoff).Msg("retrying transaction")
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("db.tx.attempt", attempts),
+			attribute.Int64("db.tx.backoff_ms", backoff.Milliseconds()),
+		))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		atomic.AddInt64(&store.counters.retries, 1)
+		metrics.TxRetries.Inc()
+	}
+}
+
+// observeTx records the latency of a transaction that started at start, with all of its attempts
+func observeTx(start time.Time, err *error) {
+	outcome := "commit"
+	if *err != nil {
+		outcome = "rollback"
+	}
+	metrics.TxDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// txAttributes returns the span attributes of the options of a transaction
+func txAttributes(opts *sql.TxOptions) []attribute.KeyValue {
+	if opts == nil {
+		return []attribute.KeyValue{attribute.String("db.tx.isolation", sql.LevelDefault.String())}
+	}
+	return []attribute.KeyValue{
+		attribute.String("db.tx.isolation", opts.Isolation.String()),
+		attribute.Bool("db.tx.read_only", opts.ReadOnly),
+	}
+}
+
+// runTx runs a single attempt of the transaction, attempt starts from 1
+func (store *SQLStore) runTx(ctx context.Context, opts *sql.TxOptions, fn func(*Queries) error, attempt int) (err error) {
+	ctx, span := tracing.Start(ctx, "execTx.attempt", trace.WithAttributes(attribute.Int("db.tx.attempt", attempt)))
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := store.db.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
 
-	q := New(tx)
+	q := New(&tracedDB{db: tx, parent: span})
 	err = fn(q)
 	if err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+			// %w keeps the original error inspectable with errors.Is
+			return fmt.Errorf("tx err: %w, rb err: %v", err, rbErr)
 		}
 		return err
 	}
@@ -59,7 +204,15 @@ func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) erro
 type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
-	Amount        int64 `json:"amount"`
+	// Amount is in the currency of the from account
+	Amount int64 `json:"amount"`
+	// ExchangeRate converts Amount to the currency of the to account
+	// it is left empty when both accounts use the same currency
+	// the fee of the transfer is not a parameter, the store charges the one of its fee schedule
+	ExchangeRate fx.Rate `json:"exchange_rate"`
+	// releasedHold is the amount of a pending hold of the from account that the transfer captures
+	// it is still counted in the held amount of the account during the transfer, see CaptureTx
+	releasedHold int64
 }
 
 // TransferTxResult is the result of the transfer transaction
@@ -69,6 +222,10 @@ type TransferTxResult struct {
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+	// FromFeeEntry charges the fee to the from account, FeeEntry credits it to the fee account
+	// both are nil for transfers without a fee
+	FromFeeEntry *Entry `json:"from_fee_entry,omitempty"`
+	FeeEntry     *Entry `json:"fee_entry,omitempty"`
 }
 
 // txKey is used as a context key for db transactions
@@ -78,81 +235,177 @@ type TransferTxResult struct {
 
 //TransferTx performs a money transfer from one account to another
 // It creates a transfer record, add account entries and update account balances within sinle tx
+// the entries of the transfer and its fee are posted as one journal, see PostJournalTx
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 
 	var result TransferTxResult
 
-	err := store.execTx(ctx, func(q *Queries) error {
+	err := store.execTx(ctx, nil, func(q *Queries) error {
 		var err error
+		result, err = transferTx(ctx, q, store.fees, store.rates, arg)
+		return err
+	})
+	if err == nil {
+		countTransfer(result)
+	}
+	return result, err
+}
 
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
-			FromAccountID: arg.FromAccountID,
-			ToAccountID:   arg.ToAccountID,
-			Amount:        arg.Amount,
-		})
+// transferTx runs the queries of a transfer with the given Queries object, the fee is quoted by fees
+// and the user limits are checked with rates, it must be called inside of a db transaction, see execTx
+func transferTx(ctx context.Context, q *Queries, fees FeeQuoter, rates fx.RateProvider, arg TransferTxParams) (result TransferTxResult, err error) {
+	// each account gets its entry in its own currency
+	toAmount := arg.Amount
+	exchangeRate := "1"
+	if arg.ExchangeRate != (fx.Rate{}) {
+		toAmount, err = arg.ExchangeRate.ConvertAmount(arg.Amount)
 		if err != nil {
-			return err
+			return
 		}
+		exchangeRate = arg.ExchangeRate.Value
+	}
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
-		})
-		if err != nil {
-			return err
-		}
+	result, err = postTransfer(ctx, q, fees, CreateTransferParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+		ToAmount:      toAmount,
+		ExchangeRate:  exchangeRate,
+	}, arg.releasedHold)
+	if err != nil {
+		return
+	}
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
-		})
-		if err != nil {
-			return err
-		}
+	// currencies of the accounts are only known after the update, a wrong or missing rate rolls back the tx
+	// every caller reaches this, e.g. captures and scheduled runs, not only the handler that checks the currencies
+	switch {
+	case arg.ExchangeRate == (fx.Rate{}) && result.FromAccount.Currency != result.ToAccount.Currency:
+		err = fmt.Errorf("%w: no exchange rate from %s to %s",
+			ErrCurrencyMismatch, result.FromAccount.Currency, result.ToAccount.Currency)
+		return
+	case arg.ExchangeRate != (fx.Rate{}) &&
+		(result.FromAccount.Currency != arg.ExchangeRate.From || result.ToAccount.Currency != arg.ExchangeRate.To):
+		err = fmt.Errorf("%w: rate %s->%s cannot be used from %s to %s",
+			ErrCurrencyMismatch, arg.ExchangeRate.From, arg.ExchangeRate.To, result.FromAccount.Currency, result.ToAccount.Currency)
+		return
+	}
 
-		// to avoid deadlock
-		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
-		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
-		}
+	err = checkTransferLimits(ctx, q, rates, result.FromAccount, arg.Amount)
+	return
+}
+
+// postTransfer creates the transfer row, and posts its legs and its fee as one journal
+// amounts are already in the currency of each account, releasedHold is the part of the held funds that can be spent
+// the fee is quoted by fees in the currency of the from account, nil fees charge nothing, e.g. for a reversal
+func postTransfer(ctx context.Context, q *Queries, fees FeeQuoter, arg CreateTransferParams, releasedHold int64) (result TransferTxResult, err error) {
+	q, span := startTxSpan(ctx, q, "postTransfer",
+		attribute.Int64("transfer.from_account_id", arg.FromAccountID),
+		attribute.Int64("transfer.to_account_id", arg.ToAccountID),
+		attribute.Int64("transfer.amount", arg.Amount),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	currencies, err := accountCurrencies(ctx, q, arg.FromAccountID, arg.ToAccountID)
+	if err != nil {
+		return
+	}
+	fromCurrency := currencies[arg.FromAccountID]
 
+	if fees != nil {
+		var quote fee.Quote
+		quote, err = fees.Quote(fromCurrency, arg.Amount)
 		if err != nil {
-			return err
+			return
 		}
+		arg.Fee = quote.Fee
+		arg.FeeAccountID = sql.NullInt64{Int64: quote.RevenueAccountID, Valid: quote.Fee > 0}
+	}
+	span.SetAttributes(attribute.Int64("transfer.fee", arg.Fee))
 
-		return err
-	})
-	return result, err
-}
-
-func addMoney(
-	ctx context.Context,
-	q *Queries,
-	accountID1 int64,
-	amount1 int64,
-	accountID2 int64,
-	amount2 int64,
-) (account1 Account, account2 Account, err error) {
+	// fee is in the currency of the from account, so the fee account must use the same one
+	if arg.Fee > 0 {
+		var feeCurrencies map[int64]string
+		feeCurrencies, err = accountCurrencies(ctx, q, arg.FeeAccountID.Int64)
+		if err != nil {
+			return
+		}
+		if feeCurrencies[arg.FeeAccountID.Int64] != fromCurrency {
+			err = fmt.Errorf("%w: fee account [%d] is in %s, not %s",
+				ErrCurrencyMismatch, arg.FeeAccountID.Int64, feeCurrencies[arg.FeeAccountID.Int64], fromCurrency)
+			return
+		}
+	}
 
-	account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID1,
-		Amount: amount1,
-	})
+	result.Transfer, err = q.CreateTransfer(ctx, arg)
 	if err != nil {
 		return
-		//return account1, account2, err
 	}
+	span.SetAttributes(attribute.Int64("transfer.id", result.Transfer.ID))
 
-	account2, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID2,
-		Amount: amount2,
+	legs := []journalLeg{
+		{Leg: Leg{AccountID: arg.FromAccountID, Amount: -arg.Amount, Currency: fromCurrency}},
+		{Leg: Leg{AccountID: arg.ToAccountID, Amount: arg.ToAmount, Currency: currencies[arg.ToAccountID]}},
+	}
+	// the rate is kept by the transfer, the credit balances the debit in the currency of the sender
+	if legs[1].Currency != fromCurrency {
+		legs[1].sourceAmount = arg.Amount
+		legs[1].sourceCurrency = fromCurrency
+	}
+	if arg.Fee > 0 {
+		legs = append(legs,
+			journalLeg{Leg: Leg{AccountID: arg.FromAccountID, Amount: -arg.Fee, Currency: fromCurrency}},
+			journalLeg{Leg: Leg{AccountID: arg.FeeAccountID.Int64, Amount: arg.Fee, Currency: fromCurrency}},
+		)
+	}
+
+	journal, err := postJournal(ctx, q, journalParams{
+		legs:         legs,
+		transferID:   sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+		releasedHold: map[int64]int64{arg.FromAccountID: releasedHold},
 	})
 	if err != nil {
 		return
-		//return account1, account2, err
+	}
+
+	result.FromEntry = journal.Entries[0]
+	result.ToEntry = journal.Entries[1]
+	if arg.Fee > 0 {
+		result.FromFeeEntry = &journal.Entries[2]
+		result.FeeEntry = &journal.Entries[3]
+	}
+
+	for _, account := range journal.Accounts {
+		switch account.ID {
+		case arg.FromAccountID:
+			result.FromAccount = account
+		case arg.ToAccountID:
+			result.ToAccount = account
+		}
 	}
 
 	return
+}
+
+// countTransfer counts a committed transfer in the metrics, it must not be called before the tx is committed
+// since a rolled back or retried transfer would be counted too
+func countTransfer(result TransferTxResult) {
+	metrics.TransferCreated(result.FromAccount.Currency, result.Transfer.Amount)
+}
 
+// accountCurrencies returns the currency of each account, zero ids are skipped
+// the currency of an account never changes, so it can be read before the rows are locked
+func accountCurrencies(ctx context.Context, q *Queries, ids ...int64) (map[int64]string, error) {
+	currencies := make(map[int64]string, len(ids))
+	for _, id := range ids {
+		if _, ok := currencies[id]; ok || id == 0 {
+			continue
+		}
+
+		account, err := q.GetAccount(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		currencies[id] = account.Currency
+	}
+	return currencies, nil
 }
@@ -2,17 +2,23 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
+	"github.com/keremakillioglu/simplebank/fee"
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTransferTx(t *testing.T) {
 
-	store := NewStore(testDB)
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
 
-	account1 := createRandomAccount(t)
+	// random balance might be less than the total amount of the transfers
+	account1 := fundAccount(t, createRandomAccount(t), 1000)
 	account2 := createRandomAccount(t)
 	fmt.Println(">> before:", account1.Balance, account2.Balance)
 
@@ -66,6 +72,7 @@ func TestTransferTx(t *testing.T) {
 		require.NotEmpty(t, fromEntry)
 		require.Equal(t, account1.ID, fromEntry.AccountID)
 		require.Equal(t, -amount, fromEntry.Amount)
+		require.Equal(t, transfer.ID, fromEntry.TransferID.Int64)
 		require.NotZero(t, fromEntry.ID)
 		require.NotZero(t, fromEntry.CreatedAt)
 
@@ -76,6 +83,7 @@ func TestTransferTx(t *testing.T) {
 		require.NotEmpty(t, toEntry)
 		require.Equal(t, account2.ID, toEntry.AccountID)
 		require.Equal(t, amount, toEntry.Amount)
+		require.Equal(t, transfer.ID, toEntry.TransferID.Int64)
 		require.NotZero(t, toEntry.ID)
 		require.NotZero(t, toEntry.CreatedAt)
 
@@ -122,10 +130,11 @@ func TestTransferTx(t *testing.T) {
 
 func TestTransferTxDeadlock(t *testing.T) {
 
-	store := NewStore(testDB)
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	// both accounts send money, so both of them need enough balance
+	account1 := fundAccount(t, createRandomAccount(t), 1000)
+	account2 := fundAccount(t, createRandomAccount(t), 1000)
 	fmt.Println(">> before:", account1.Balance, account2.Balance)
 
 	n := 10
@@ -175,3 +184,200 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
 
 }
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccount(t), 10)
+	account2 := createRandomAccount(t)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        account1.Balance + 1,
+	})
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	// the whole transaction must be rolled back
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+
+	updatedAccount2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+
+	// overdraft limit lets the balance go below zero
+	account1, err = testQueries.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: 5,
+	})
+	require.NoError(t, err)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        account1.Balance + account1.OverdraftLimit,
+	})
+	require.NoError(t, err)
+	require.Equal(t, -account1.OverdraftLimit, result.FromAccount.Balance)
+}
+
+// fundAccount sets the balance of the account, so it can afford the transfers of the test
+func fundAccount(t *testing.T, account Account, balance int64) Account {
+	account, err := testQueries.UpdateAccount(context.Background(), UpdateAccountParams{
+		ID:      account.ID,
+		Balance: balance,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestTransferTxCrossCurrency(t *testing.T) {
+
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account1 = fundAccount(t, account1, 1000)
+	account2 := createRandomAccountWithCurrency(t, util.EUR)
+
+	rate := fx.Rate{From: util.USD, To: util.EUR, Value: "0.92"}
+	amount := int64(101)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+		ExchangeRate:  rate,
+	})
+	require.NoError(t, err)
+
+	// both amounts and the rate are recorded on the transfer
+	require.Equal(t, amount, result.Transfer.Amount)
+	require.Equal(t, int64(93), result.Transfer.ToAmount)
+	require.Equal(t, "0.92000000", result.Transfer.ExchangeRate)
+
+	// each entry is in the currency of its account
+	require.Equal(t, -amount, result.FromEntry.Amount)
+	require.Equal(t, int64(93), result.ToEntry.Amount)
+	require.Equal(t, account1.Balance-amount, result.FromAccount.Balance)
+	require.Equal(t, account2.Balance+93, result.ToAccount.Balance)
+
+	// rate of another currency pair is rejected and the tx is rolled back
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+		ExchangeRate:  fx.Rate{From: util.USD, To: util.TRY, Value: "32.15"},
+	})
+	require.True(t, errors.Is(err, ErrCurrencyMismatch))
+
+	updatedAccount2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.ToAccount.Balance, updatedAccount2.Balance)
+}
+
+func TestTransferTxCurrencyMismatchWithoutRate(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 1000)
+	account2 := createRandomAccountWithCurrency(t, util.EUR)
+
+	// without a rate the amount would be credited 1:1 in another currency
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.True(t, errors.Is(err, ErrCurrencyMismatch), err)
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+
+	updatedAccount2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+}
+
+// createRandomAccountWithCurrency creates an account of a new random user in the given currency
+func createRandomAccountWithCurrency(t *testing.T, currency string) Account {
+	user := createRandomUser(t)
+
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  util.RandomMoney(),
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+// newFeeStore creates a store charging a flat fee in the currency of the fee account, credited to it
+func newFeeStore(t *testing.T, feeAccount Account, flat int64) Store {
+	schedule, err := fee.NewSchedule(
+		[]fee.Rule{{Currency: feeAccount.Currency, Flat: flat}},
+		map[string]int64{feeAccount.Currency: feeAccount.ID},
+	)
+	require.NoError(t, err)
+	return NewStore(testDB, zerolog.Nop(), schedule, nil)
+}
+
+func TestTransferTxWithFee(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	feeAccount := createRandomAccountWithCurrency(t, util.USD)
+	store := newFeeStore(t, feeAccount, 10)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        90,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(10), result.Transfer.Fee)
+	require.Equal(t, feeAccount.ID, result.Transfer.FeeAccountID.Int64)
+	require.Equal(t, int64(-90), result.FromEntry.Amount)
+
+	// fee is a separate pair of entries of the same transfer
+	require.NotNil(t, result.FromFeeEntry)
+	require.NotNil(t, result.FeeEntry)
+	require.Equal(t, account1.ID, result.FromFeeEntry.AccountID)
+	require.Equal(t, int64(-10), result.FromFeeEntry.Amount)
+	require.Equal(t, feeAccount.ID, result.FeeEntry.AccountID)
+	require.Equal(t, int64(10), result.FeeEntry.Amount)
+	require.Equal(t, result.Transfer.ID, result.FeeEntry.TransferID.Int64)
+
+	require.Zero(t, result.FromAccount.Balance)
+	require.Equal(t, account2.Balance+90, result.ToAccount.Balance)
+
+	updatedFeeAccount, err := testQueries.GetAccount(context.Background(), feeAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, feeAccount.Balance+10, updatedFeeAccount.Balance)
+
+	// the balance doesnt cover the fee anymore, nothing is posted
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        account2.Balance + 90,
+	})
+	require.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	// fee account in another currency
+	eurFeeAccount := createRandomAccountWithCurrency(t, util.EUR)
+	schedule, err := fee.NewSchedule(
+		[]fee.Rule{{Currency: util.USD, Flat: 1}},
+		map[string]int64{util.USD: eurFeeAccount.ID},
+	)
+	require.NoError(t, err)
+
+	_, err = NewStore(testDB, zerolog.Nop(), schedule, nil).TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        1,
+	})
+	require.True(t, errors.Is(err, ErrCurrencyMismatch))
+}
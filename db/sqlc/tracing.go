@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/keremakillioglu/simplebank/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedDB runs the queries of a Queries object in spans named after the sqlc query, e.g. GetAccount
+type tracedDB struct {
+	db DBTX
+	// parent is the span the queries belong to in a transaction, see runTx and startTxSpan
+	// the fn of a transaction keeps the ctx of its caller, so the span cannot be taken from there
+	// outside of transactions it is nil, and the span of the ctx is used
+	parent trace.Span
+}
+
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := t.start(ctx, query, args)
+
+	result, err := t.db.ExecContext(ctx, query, args...)
+	if err == nil {
+		if rows, rowsErr := result.RowsAffected(); rowsErr == nil {
+			span.SetAttributes(attribute.Int64("db.rows_affected", rows))
+		}
+	}
+
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := t.start(ctx, query, nil)
+
+	stmt, err := t.db.PrepareContext(ctx, query)
+	tracing.End(span, err)
+	return stmt, err
+}
+
+// QueryContext ends the span when the caller closes the rows, with the number of rows read
+// that needs the driver of OpenDB, with other drivers it only measures the query until the first row is ready
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := t.start(ctx, query, args)
+
+	counter := &rowCounter{span: span}
+	rows, err := t.db.QueryContext(context.WithValue(ctx, rowCounterKey{}, counter), query, args...)
+	if err != nil || !counter.counting {
+		tracing.End(span, err)
+	}
+	return rows, err
+}
+
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := t.start(ctx, query, args)
+
+	// sql.ErrNoRows is returned by Scan, so a missing row is not an error of the span
+	row := t.db.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+type rowCounterKey struct{}
+
+// rowCounter is passed to the driver in the ctx of a query, see countingConn
+type rowCounter struct {
+	span trace.Span
+	// set by the driver when it counts the rows, the span is ended by done then
+	counting bool
+}
+
+// done is called when the rows are closed, err is the error of reading them
+func (c *rowCounter) done(rows int64, err error) {
+	c.span.SetAttributes(attribute.Int64("db.rows_affected", rows))
+	tracing.End(c.span, err)
+}
+
+func (t *tracedDB) start(ctx context.Context, query string, args []interface{}) (context.Context, trace.Span) {
+	if t.parent != nil {
+		ctx = trace.ContextWithSpan(ctx, t.parent)
+	}
+
+	name := queryName(query)
+	return tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String("postgresql"),
+			semconv.DBOperationKey.String(name),
+			semconv.DBStatementKey.String(query),
+			// ids and amounts, strings are left out since they can be personal data like emails
+			attribute.Array("db.int_args", intArgs(args)),
+		),
+	)
+}
+
+// queryName returns the name sqlc puts in the first line of the query, "-- name: GetAccount :one"
+func queryName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) >= 3 && fields[0] == "--" && fields[1] == "name:" {
+		return fields[2]
+	}
+	return "query"
+}
+
+// intArgs returns the integer arguments of a query, including the valid nullable ones
+func intArgs(args []interface{}) []int64 {
+	values := make([]int64, 0, len(args))
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case int64:
+			values = append(values, v)
+		case int32:
+			values = append(values, int64(v))
+		case sql.NullInt64:
+			if v.Valid {
+				values = append(values, v.Int64)
+			}
+		}
+	}
+	return values
+}
+
+// startTxSpan starts a span in the transaction of q, e.g. for a transfer of a batch
+// the queries run with the returned Queries object are the children of the span
+func startTxSpan(ctx context.Context, q *Queries, name string, attrs ...attribute.KeyValue) (*Queries, trace.Span) {
+	traced, ok := q.db.(*tracedDB)
+	if ok && traced.parent != nil {
+		ctx = trace.ContextWithSpan(ctx, traced.parent)
+	}
+
+	_, span := tracing.Start(ctx, name, trace.WithAttributes(attrs...))
+	if !ok {
+		return q, span
+	}
+	return New(&tracedDB{db: traced.db, parent: span}), span
+}
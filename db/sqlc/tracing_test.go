@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	require.Equal(t, "GetAccount", queryName(getAccount))
+	require.Equal(t, "CreateTransfer", queryName(createTransfer))
+	require.Equal(t, "query", queryName("SELECT 1"))
+	require.Equal(t, "query", queryName(""))
+}
+
+func TestIntArgs(t *testing.T) {
+	args := []interface{}{
+		int64(1),
+		"owner@example.com",
+		int32(2),
+		sql.NullInt64{Int64: 3, Valid: true},
+		sql.NullInt64{},
+		true,
+	}
+	require.Equal(t, []int64{1, 2, 3}, intArgs(args))
+	require.Empty(t, intArgs(nil))
+}
+
+func TestTransferTxSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+	exporter.Reset()
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	spans := make(map[string]*sdktrace.SpanSnapshot)
+	for _, span := range exporter.GetSpans() {
+		// the first span of each name is kept, e.g. the first CreateEntry
+		if _, ok := spans[span.Name]; !ok {
+			spans[span.Name] = span
+		}
+	}
+
+	// execTx > execTx.attempt > postTransfer > postJournal, with the queries in each of them
+	parents := map[string]string{
+		"execTx.attempt":     "execTx",
+		"postTransfer":       "execTx.attempt",
+		"CreateTransfer":     "postTransfer",
+		"postJournal":        "postTransfer",
+		"CreateJournalEntry": "postJournal",
+		"CreateEntry":        "postJournal",
+		"AddAccountBalance":  "postJournal",
+	}
+	for name, parent := range parents {
+		require.Contains(t, spans, name)
+		require.Contains(t, spans, parent)
+		require.Equal(t, spans[parent].SpanContext.SpanID(), spans[name].Parent.SpanID(), name)
+	}
+
+	attributes := func(span *sdktrace.SpanSnapshot) map[attribute.Key]attribute.Value {
+		values := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes {
+			values[kv.Key] = kv.Value
+		}
+		return values
+	}
+
+	require.Equal(t, int64(1), attributes(spans["execTx"])["db.tx.attempts"].AsInt64())
+	require.Equal(t, account1.ID, attributes(spans["postTransfer"])["transfer.from_account_id"].AsInt64())
+	require.Equal(t, account2.ID, attributes(spans["postTransfer"])["transfer.to_account_id"].AsInt64())
+	require.Equal(t, result.Transfer.ID, attributes(spans["postTransfer"])["transfer.id"].AsInt64())
+	require.Equal(t, "CreateTransfer", attributes(spans["CreateTransfer"])["db.operation"].AsString())
+	require.Equal(t, [2]int64{account1.ID, account2.ID}, attributes(spans["postJournal"])["journal.account_ids"].AsArray())
+}
+
+func TestQuerySpanRows(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+	account1 := fundAccount(t, createRandomAccountWithCurrency(t, util.USD), 100)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	from := time.Now().Add(-time.Minute)
+	for i := 0; i < 2; i++ {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		})
+		require.NoError(t, err)
+	}
+	exporter.Reset()
+
+	rows, err := store.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		FromTime:  from,
+		ToTime:    time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	// the span of the query is ended when its rows are closed
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "ListStatementEntries", spans[0].Name)
+	require.Contains(t, spans[0].Attributes, attribute.Int64("db.rows_affected", 2))
+}
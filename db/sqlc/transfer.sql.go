@@ -5,26 +5,82 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
+const addTransferReversedAmount = `-- name: AddTransferReversedAmount :one
+UPDATE transfers
+SET reversed_amount = reversed_amount + $1
+WHERE id = $2
+RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, reversal_of, reversed_amount, batch_id, fee, fee_account_id
+`
+
+type AddTransferReversedAmountParams struct {
+	Amount int64 `json:"amount"`
+	ID     int64 `json:"id"`
+}
+
+func (q *Queries) AddTransferReversedAmount(ctx context.Context, arg AddTransferReversedAmountParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, addTransferReversedAmount, arg.Amount, arg.ID)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.ReversalOf,
+		&i.ReversedAmount,
+		&i.BatchID,
+		&i.Fee,
+		&i.FeeAccountID,
+	)
+	return i, err
+}
+
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  to_amount,
+  exchange_rate,
+  reversal_of,
+  batch_id,
+  fee,
+  fee_account_id
 ) VALUES (
-  $1, $2, $3
-) RETURNING id, from_account_id, to_account_id, amount, created_at
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, reversal_of, reversed_amount, batch_id, fee, fee_account_id
 `
 
 type CreateTransferParams struct {
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
-	Amount        int64 `json:"amount"`
+	FromAccountID int64         `json:"from_account_id"`
+	ToAccountID   int64         `json:"to_account_id"`
+	Amount        int64         `json:"amount"`
+	ToAmount      int64         `json:"to_amount"`
+	ExchangeRate  string        `json:"exchange_rate"`
+	ReversalOf    sql.NullInt64 `json:"reversal_of"`
+	BatchID       sql.NullInt64 `json:"batch_id"`
+	Fee           int64         `json:"fee"`
+	FeeAccountID  sql.NullInt64 `json:"fee_account_id"`
 }
 
 func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
-	row := q.db.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ToAmount,
+		arg.ExchangeRate,
+		arg.ReversalOf,
+		arg.BatchID,
+		arg.Fee,
+		arg.FeeAccountID,
+	)
 	var i Transfer
 	err := row.Scan(
 		&i.ID,
@@ -32,12 +88,43 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.ReversalOf,
+		&i.ReversedAmount,
+		&i.BatchID,
+		&i.Fee,
+		&i.FeeAccountID,
 	)
 	return i, err
 }
 
+const getOutgoingTransferTotals = `-- name: GetOutgoingTransferTotals :one
+SELECT
+  COALESCE(SUM(amount) FILTER (WHERE created_at >= now() - interval '24 hours'), 0)::bigint AS daily_amount,
+  COALESCE(SUM(amount), 0)::bigint AS monthly_amount,
+  COUNT(*) FILTER (WHERE created_at >= now() - interval '1 hour') AS hourly_count
+FROM transfers
+WHERE from_account_id = $1 AND reversal_of IS NULL AND created_at >= now() - interval '30 days'
+`
+
+type GetOutgoingTransferTotalsRow struct {
+	DailyAmount   int64 `json:"daily_amount"`
+	MonthlyAmount int64 `json:"monthly_amount"`
+	HourlyCount   int64 `json:"hourly_count"`
+}
+
+// totals of the rolling windows of the limits, ending now
+// reversals are refunds, they dont count against the limits of the sender
+func (q *Queries) GetOutgoingTransferTotals(ctx context.Context, fromAccountID int64) (GetOutgoingTransferTotalsRow, error) {
+	row := q.db.QueryRowContext(ctx, getOutgoingTransferTotals, fromAccountID)
+	var i GetOutgoingTransferTotalsRow
+	err := row.Scan(&i.DailyAmount, &i.MonthlyAmount, &i.HourlyCount)
+	return i, err
+}
+
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, reversal_of, reversed_amount, batch_id, fee, fee_account_id FROM transfers
 WHERE id = $1 LIMIT 1
 `
 
@@ -50,34 +137,235 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.ReversalOf,
+		&i.ReversedAmount,
+		&i.BatchID,
+		&i.Fee,
+		&i.FeeAccountID,
 	)
 	return i, err
 }
 
+const getTransferForUpdate = `-- name: GetTransferForUpdate :one
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, reversal_of, reversed_amount, batch_id, fee, fee_account_id FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, getTransferForUpdate, id)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.ReversalOf,
+		&i.ReversedAmount,
+		&i.BatchID,
+		&i.Fee,
+		&i.FeeAccountID,
+	)
+	return i, err
+}
+
+const listAccountTransfers = `-- name: ListAccountTransfers :many
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, reversal_of, reversed_amount, batch_id, fee, fee_account_id FROM transfers
+WHERE
+    (($1::boolean AND from_account_id = $2) OR
+     ($3::boolean AND to_account_id = $2)) AND
+    created_at >= $4 AND
+    created_at < $5 AND
+    (CASE WHEN from_account_id = $2 THEN amount ELSE to_amount END)
+        BETWEEN $6::bigint AND $7::bigint AND
+    ($8::bigint = 0 OR
+     from_account_id = $8 OR
+     to_account_id = $8) AND
+    (created_at, id) < ($9::timestamptz, $10::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT $11
+`
+
+type ListAccountTransfersParams struct {
+	Outgoing        bool      `json:"outgoing"`
+	AccountID       int64     `json:"account_id"`
+	Incoming        bool      `json:"incoming"`
+	FromTime        time.Time `json:"from_time"`
+	ToTime          time.Time `json:"to_time"`
+	MinAmount       int64     `json:"min_amount"`
+	MaxAmount       int64     `json:"max_amount"`
+	CounterpartyID  int64     `json:"counterparty_id"`
+	BeforeCreatedAt time.Time `json:"before_created_at"`
+	BeforeID        int64     `json:"before_id"`
+	Limit           int32     `json:"limit"`
+}
+
+// amounts are compared in the currency of the account, so incoming transfers use to_amount
+// newest first, the next page starts before the last transfer of the previous one
+func (q *Queries) ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountTransfers,
+		arg.Outgoing,
+		arg.AccountID,
+		arg.Incoming,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.CounterpartyID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.ReversalOf,
+			&i.ReversedAmount,
+			&i.BatchID,
+			&i.Fee,
+			&i.FeeAccountID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOwnerOutgoingTransferTotals = `-- name: ListOwnerOutgoingTransferTotals :many
+SELECT
+  a.currency,
+  COALESCE(SUM(t.amount) FILTER (WHERE t.created_at >= now() - interval '24 hours'), 0)::bigint AS daily_amount,
+  COALESCE(SUM(t.amount), 0)::bigint AS monthly_amount,
+  COUNT(*) FILTER (WHERE t.created_at >= now() - interval '1 hour') AS hourly_count
+FROM transfers t
+JOIN accounts a ON a.id = t.from_account_id
+WHERE a.owner = $1 AND t.reversal_of IS NULL AND t.created_at >= now() - interval '30 days'
+GROUP BY a.currency
+ORDER BY a.currency
+`
+
+type ListOwnerOutgoingTransferTotalsRow struct {
+	Currency      string `json:"currency"`
+	DailyAmount   int64  `json:"daily_amount"`
+	MonthlyAmount int64  `json:"monthly_amount"`
+	HourlyCount   int64  `json:"hourly_count"`
+}
+
+// totals of the user limits, one row for each currency of the accounts of the owner
+// amounts are in the currency of the accounts, they are converted before they are summed
+// reversals are refunds, they dont count against the limits of the sender
+func (q *Queries) ListOwnerOutgoingTransferTotals(ctx context.Context, owner string) ([]ListOwnerOutgoingTransferTotalsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listOwnerOutgoingTransferTotals, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListOwnerOutgoingTransferTotalsRow{}
+	for rows.Next() {
+		var i ListOwnerOutgoingTransferTotalsRow
+		if err := rows.Scan(
+			&i.Currency,
+			&i.DailyAmount,
+			&i.MonthlyAmount,
+			&i.HourlyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransferReversals = `-- name: ListTransferReversals :many
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, reversal_of, reversed_amount, batch_id, fee, fee_account_id FROM transfers
+WHERE reversal_of = $1
+ORDER BY id
+`
+
+func (q *Queries) ListTransferReversals(ctx context.Context, reversalOf sql.NullInt64) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listTransferReversals, reversalOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.ReversalOf,
+			&i.ReversedAmount,
+			&i.BatchID,
+			&i.Fee,
+			&i.FeeAccountID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
-WHERE 
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, reversal_of, reversed_amount, batch_id, fee, fee_account_id FROM transfers
+WHERE
     from_account_id = $1 OR
-    to_account_id = $2
+    to_account_id = $1
 ORDER BY id
-LIMIT $3
-OFFSET $4
+LIMIT $2
+OFFSET $3
 `
 
 type ListTransfersParams struct {
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
-	Limit         int32 `json:"limit"`
-	Offset        int32 `json:"offset"`
+	AccountID int64 `json:"account_id"`
+	Limit     int32 `json:"limit"`
+	Offset    int32 `json:"offset"`
 }
 
 func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error) {
-	rows, err := q.db.QueryContext(ctx, listTransfers,
-		arg.FromAccountID,
-		arg.ToAccountID,
-		arg.Limit,
-		arg.Offset,
-	)
+	rows, err := q.db.QueryContext(ctx, listTransfers, arg.AccountID, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +379,13 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.ReversalOf,
+			&i.ReversedAmount,
+			&i.BatchID,
+			&i.Fee,
+			&i.FeeAccountID,
 		); err != nil {
 			return nil, err
 		}
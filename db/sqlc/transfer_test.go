@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAccountTransfers(t *testing.T) {
+	store := NewStore(testDB, zerolog.Nop(), nil, nil)
+
+	account1 := fundAccount(t, createRandomAccount(t), 1000)
+	account2 := fundAccount(t, createRandomAccountWithCurrency(t, account1.Currency), 1000)
+	account3 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	transfer := func(from, to Account, amount int64) Transfer {
+		result, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: from.ID,
+			ToAccountID:   to.ID,
+			Amount:        amount,
+		})
+		require.NoError(t, err)
+		return result.Transfer
+	}
+
+	outgoing1 := transfer(account1, account2, 10)
+	incoming := transfer(account2, account1, 20)
+	outgoing2 := transfer(account1, account3, 30)
+
+	all := ListAccountTransfersParams{
+		AccountID:       account1.ID,
+		Incoming:        true,
+		Outgoing:        true,
+		ToTime:          time.Now().Add(time.Minute),
+		MaxAmount:       math.MaxInt64,
+		BeforeCreatedAt: time.Now().Add(time.Minute),
+		BeforeID:        math.MaxInt64,
+		Limit:           10,
+	}
+
+	// newest first
+	transfers, err := testQueries.ListAccountTransfers(context.Background(), all)
+	require.NoError(t, err)
+	require.Equal(t, []int64{outgoing2.ID, incoming.ID, outgoing1.ID}, transferIDs(transfers))
+
+	arg := all
+	arg.Incoming = false
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, []int64{outgoing2.ID, outgoing1.ID}, transferIDs(transfers))
+
+	arg = all
+	arg.CounterpartyID = account2.ID
+	arg.MinAmount = 15
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, []int64{incoming.ID}, transferIDs(transfers))
+
+	// next page starts after the last transfer of the previous one
+	arg = all
+	arg.Limit = 2
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+
+	arg.BeforeCreatedAt = transfers[1].CreatedAt
+	arg.BeforeID = transfers[1].ID
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, []int64{outgoing1.ID}, transferIDs(transfers))
+}
+
+func transferIDs(transfers []Transfer) []int64 {
+	ids := make([]int64, len(transfers))
+	for i, transfer := range transfers {
+		ids[i] = transfer.ID
+	}
+	return ids
+}
@@ -0,0 +1,164 @@
+package fee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// Different types of error returned by the fee schedule
+var (
+	ErrInvalidRule = errors.New("fee rule is invalid")
+	// ErrNoRevenueAccount is returned when a fee is charged in a currency without a revenue account
+	ErrNoRevenueAccount = errors.New("no fee revenue account")
+)
+
+// basisPoints in 100%, a basis point is 0.01%
+const basisPoints = 10000
+
+// Rule computes the fee of a transfer: flat + amount * basis_points / 10000, limited by min and max
+// all amounts are in the minor units of the currency, e.g. cents
+type Rule struct {
+	// Currency is the currency of the from account, the rule without a currency is used for all the others
+	Currency    string `json:"currency"`
+	Flat        int64  `json:"flat"`
+	BasisPoints int64  `json:"basis_points"`
+	Min         int64  `json:"min"`
+	// Max is the cap of the fee, zero means no cap
+	Max int64 `json:"max"`
+}
+
+// Validate checks if the rule cannot produce a negative fee
+func (rule Rule) Validate() error {
+	if rule.Flat < 0 || rule.BasisPoints < 0 || rule.BasisPoints > basisPoints || rule.Min < 0 || rule.Max < 0 {
+		return fmt.Errorf("%w: %q", ErrInvalidRule, rule.Currency)
+	}
+	if rule.Max > 0 && rule.Max < rule.Min {
+		return fmt.Errorf("%w: %q max is less than min", ErrInvalidRule, rule.Currency)
+	}
+	return nil
+}
+
+// Fee returns the fee of the amount, the percentage is rounded half up to the nearest minor unit
+func (rule Rule) Fee(amount int64) int64 {
+	// big.Int, so amount * basis points cannot overflow
+	percentage := new(big.Int).Mul(big.NewInt(amount), big.NewInt(rule.BasisPoints))
+	percentage.Add(percentage, big.NewInt(basisPoints/2))
+	percentage.Quo(percentage, big.NewInt(basisPoints))
+
+	fee := new(big.Int).Add(percentage, big.NewInt(rule.Flat))
+	if fee.Cmp(big.NewInt(rule.Min)) < 0 {
+		return rule.Min
+	}
+	if rule.Max > 0 && fee.Cmp(big.NewInt(rule.Max)) > 0 {
+		return rule.Max
+	}
+	return fee.Int64()
+}
+
+// Quote is the fee of a transfer
+type Quote struct {
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+	Fee      int64  `json:"fee"`
+	// RevenueAccountID is the account credited with the fee, zero when there is no fee
+	RevenueAccountID int64 `json:"-"`
+}
+
+// Schedule contains the fee rules and the revenue accounts of each currency
+type Schedule struct {
+	rules       map[string]Rule
+	defaultRule *Rule
+	accounts    map[string]int64
+}
+
+// scheduleFile is the format of the fee schedule file
+type scheduleFile struct {
+	Rules []Rule `json:"rules"`
+	// RevenueAccounts maps a currency to the account that collects the fees in that currency
+	RevenueAccounts map[string]int64 `json:"revenue_accounts"`
+}
+
+// NewSchedule creates a new fee schedule, an empty schedule charges no fees
+func NewSchedule(rules []Rule, revenueAccounts map[string]int64) (*Schedule, error) {
+	schedule := &Schedule{
+		rules:    make(map[string]Rule, len(rules)),
+		accounts: make(map[string]int64, len(revenueAccounts)),
+	}
+
+	for i := range rules {
+		rule := rules[i]
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+
+		if len(rule.Currency) == 0 {
+			if schedule.defaultRule != nil {
+				return nil, fmt.Errorf("%w: duplicate default rule", ErrInvalidRule)
+			}
+			schedule.defaultRule = &rule
+			continue
+		}
+
+		if _, ok := schedule.rules[rule.Currency]; ok {
+			return nil, fmt.Errorf("%w: duplicate rule of %s", ErrInvalidRule, rule.Currency)
+		}
+		schedule.rules[rule.Currency] = rule
+	}
+
+	for currency, accountID := range revenueAccounts {
+		if accountID <= 0 {
+			return nil, fmt.Errorf("invalid revenue account %d of %s", accountID, currency)
+		}
+		schedule.accounts[currency] = accountID
+	}
+
+	return schedule, nil
+}
+
+// LoadSchedule reads the fee schedule from a JSON file
+// {"rules": [{"currency": "USD", "flat": 25, "basis_points": 50, "min": 50, "max": 1000}, ...], "revenue_accounts": {"USD": 1, ...}}
+func LoadSchedule(path string) (*Schedule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fee schedule file: %w", err)
+	}
+
+	var file scheduleFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot parse fee schedule file: %w", err)
+	}
+
+	return NewSchedule(file.Rules, file.RevenueAccounts)
+}
+
+// Quote returns the fee of a transfer of amount in currency
+func (schedule *Schedule) Quote(currency string, amount int64) (Quote, error) {
+	quote := Quote{
+		Currency: currency,
+		Amount:   amount,
+	}
+
+	rule, ok := schedule.rules[currency]
+	if !ok {
+		if schedule.defaultRule == nil {
+			return quote, nil
+		}
+		rule = *schedule.defaultRule
+	}
+
+	quote.Fee = rule.Fee(amount)
+	if quote.Fee == 0 {
+		return quote, nil
+	}
+
+	// a fee that cannot be collected is an error of the schedule, the transfer is not made for free
+	accountID, ok := schedule.accounts[currency]
+	if !ok {
+		return quote, fmt.Errorf("%w: %s", ErrNoRevenueAccount, currency)
+	}
+	quote.RevenueAccountID = accountID
+	return quote, nil
+}
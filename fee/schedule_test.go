@@ -0,0 +1,84 @@
+package fee
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleFee(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     Rule
+		amount   int64
+		expected int64
+	}{
+		{"FLAT", Rule{Flat: 25}, 1000, 25},
+		{"PERCENTAGE", Rule{BasisPoints: 150}, 1000, 15},
+		{"ROUNDHALFUP", Rule{BasisPoints: 50}, 101, 1}, // 0.505
+		{"FLATANDPERCENTAGE", Rule{Flat: 25, BasisPoints: 50}, 10000, 75},
+		{"MIN", Rule{BasisPoints: 50, Min: 50}, 1000, 50},
+		{"MAX", Rule{BasisPoints: 50, Max: 1000}, 1000000, 1000},
+		{"NOFEE", Rule{}, 1000, 0},
+		{"NOOVERFLOW", Rule{BasisPoints: 10000}, 1 << 62, 1 << 62},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.rule.Validate())
+			require.Equal(t, tc.expected, tc.rule.Fee(tc.amount))
+		})
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	require.Error(t, Rule{Flat: -1}.Validate())
+	require.Error(t, Rule{BasisPoints: 10001}.Validate())
+	require.Error(t, Rule{Min: 100, Max: 50}.Validate())
+}
+
+func TestLoadSchedule(t *testing.T) {
+	schedule, err := LoadSchedule("testdata/fees.json")
+	require.NoError(t, err)
+
+	quote, err := schedule.Quote("USD", 10000)
+	require.NoError(t, err)
+	require.Equal(t, int64(75), quote.Fee)
+	require.Equal(t, int64(1), quote.RevenueAccountID)
+
+	quote, err = schedule.Quote("EUR", 10000)
+	require.NoError(t, err)
+	require.Equal(t, int64(30), quote.Fee)
+	require.Equal(t, int64(2), quote.RevenueAccountID)
+
+	// default rule is used, but there is no account to collect the fee
+	_, err = schedule.Quote("TRY", 10000)
+	require.True(t, errors.Is(err, ErrNoRevenueAccount))
+
+	// no revenue account is needed without a fee
+	quote, err = schedule.Quote("TRY", 10)
+	require.NoError(t, err)
+	require.Zero(t, quote.Fee)
+	require.Zero(t, quote.RevenueAccountID)
+}
+
+func TestNewScheduleErrors(t *testing.T) {
+	_, err := NewSchedule([]Rule{{Currency: "USD"}, {Currency: "USD"}}, nil)
+	require.True(t, errors.Is(err, ErrInvalidRule))
+
+	_, err = NewSchedule([]Rule{{}, {}}, nil)
+	require.True(t, errors.Is(err, ErrInvalidRule))
+
+	_, err = NewSchedule(nil, map[string]int64{"USD": 0})
+	require.Error(t, err)
+
+	// empty schedule charges nothing
+	schedule, err := NewSchedule(nil, nil)
+	require.NoError(t, err)
+	quote, err := schedule.Quote("USD", 1000)
+	require.NoError(t, err)
+	require.Zero(t, quote.Fee)
+}
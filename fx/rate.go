@@ -0,0 +1,132 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/keremakillioglu/simplebank/util"
+)
+
+// Different types of error returned by the rate providers and conversions
+var (
+	ErrRateNotFound   = errors.New("exchange rate not found")
+	ErrInvalidRate    = errors.New("exchange rate is invalid")
+	ErrAmountTooSmall = errors.New("converted amount is too small")
+)
+
+// Rate is the exchange rate between two currencies
+type Rate struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Value is the decimal amount of To currency paid for one unit of From currency, e.g. "0.92"
+	// decimal string is used instead of float64, so no precision is lost on the way to the db
+	Value string `json:"value"`
+}
+
+// RateProvider is an interface for getting exchange rates
+// rates can come from a static file, a db table or an external service
+type RateProvider interface {
+	// GetRate returns the rate to convert an amount in from currency to currency
+	GetRate(ctx context.Context, from string, to string) (Rate, error)
+}
+
+// parse returns the value of the rate as an exact rational number
+func (rate Rate) parse() (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(rate.Value)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %s->%s %q", ErrInvalidRate, rate.From, rate.To, rate.Value)
+	}
+	return value, nil
+}
+
+// Validate checks if the rate has currencies and a positive decimal value
+func (rate Rate) Validate() error {
+	if len(rate.From) == 0 || len(rate.To) == 0 {
+		return fmt.Errorf("%w: missing currency", ErrInvalidRate)
+	}
+	_, err := rate.parse()
+	return err
+}
+
+// rateDecimals is the scale of the rates stored in the db
+const rateDecimals = 8
+
+// Invert returns the rate in the opposite direction, rounded to the scale of the db rates
+func (rate Rate) Invert() (Rate, error) {
+	value, err := rate.parse()
+	if err != nil {
+		return Rate{}, err
+	}
+
+	return Rate{
+		From:  rate.To,
+		To:    rate.From,
+		Value: new(big.Rat).Inv(value).FloatString(rateDecimals),
+	}, nil
+}
+
+// Convert converts an amount of From currency to To currency
+// both amounts are in the same scale, see ConvertMinorUnits for currencies with different minor units
+// result is rounded half up to the nearest integer, and it must not be zero
+func (rate Rate) Convert(amount int64) (int64, error) {
+	return rate.ConvertMinorUnits(amount, 0, 0)
+}
+
+// ConvertAmount converts an amount in the minor units of From currency to the minor units of To currency
+// the minor units of both currencies are taken from the currency registry
+func (rate Rate) ConvertAmount(amount int64) (int64, error) {
+	from, ok := util.LookupCurrency(rate.From)
+	if !ok {
+		return 0, fmt.Errorf("%w: unsupported currency %s", ErrInvalidRate, rate.From)
+	}
+	to, ok := util.LookupCurrency(rate.To)
+	if !ok {
+		return 0, fmt.Errorf("%w: unsupported currency %s", ErrInvalidRate, rate.To)
+	}
+	return rate.ConvertMinorUnits(amount, from.MinorUnits, to.MinorUnits)
+}
+
+// ConvertMinorUnits converts an amount with fromMinorUnits decimal places to an amount with toMinorUnits decimal places
+// rate value is per one whole unit, e.g. 100 cents at a USD->JPY rate of 150 is 150 yen, not 15000
+// result is rounded half up to the nearest integer, and it must not be zero
+func (rate Rate) ConvertMinorUnits(amount int64, fromMinorUnits int, toMinorUnits int) (int64, error) {
+	value, err := rate.parse()
+	if err != nil {
+		return 0, err
+	}
+
+	result := new(big.Rat).Mul(value, new(big.Rat).SetInt64(amount))
+
+	// move the amount to the scale of the to currency
+	if exp := toMinorUnits - fromMinorUnits; exp != 0 {
+		scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil))
+		if exp > 0 {
+			result.Mul(result, scale)
+		} else {
+			result.Quo(result, scale)
+		}
+	}
+
+	// round half up: floor(result + 1/2)
+	// denominator of a big.Rat is always positive, so Euclidean division is the floor
+	result.Add(result, big.NewRat(1, 2))
+	converted := new(big.Int).Div(result.Num(), result.Denom())
+
+	if !converted.IsInt64() {
+		return 0, fmt.Errorf("converted amount overflows: %s", converted)
+	}
+	if converted.Sign() == 0 && amount != 0 {
+		return 0, ErrAmountTooSmall
+	}
+
+	return converted.Int64(), nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
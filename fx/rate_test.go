@@ -0,0 +1,96 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateConvert(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		amount   int64
+		expected int64
+	}{
+		{"SAMEVALUE", "1", 1234, 1234},
+		{"ROUNDDOWN", "0.92", 101, 93}, // 92.92
+		{"ROUNDHALFUP", "0.5", 3, 2},   // 1.5
+		{"LARGERATE", "32.15", 100, 3215},
+		{"MANYDECIMALS", "0.12345678", 100000000, 12345678},
+		{"NEGATIVEAMOUNT", "0.92", -101, -93},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			rate := Rate{From: "USD", To: "EUR", Value: tc.value}
+			converted, err := rate.Convert(tc.amount)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, converted)
+		})
+	}
+}
+
+func TestRateConvertErrors(t *testing.T) {
+	_, err := Rate{From: "USD", To: "EUR", Value: "abc"}.Convert(100)
+	require.True(t, errors.Is(err, ErrInvalidRate))
+
+	_, err = Rate{From: "USD", To: "EUR", Value: "-1"}.Convert(100)
+	require.True(t, errors.Is(err, ErrInvalidRate))
+
+	_, err = Rate{From: "TRY", To: "USD", Value: "0.031"}.Convert(10)
+	require.True(t, errors.Is(err, ErrAmountTooSmall))
+}
+
+func TestRateInvert(t *testing.T) {
+	inverted, err := Rate{From: "USD", To: "EUR", Value: "0.8"}.Invert()
+	require.NoError(t, err)
+	require.Equal(t, Rate{From: "EUR", To: "USD", Value: "1.25000000"}, inverted)
+
+	inverted, err = Rate{From: "USD", To: "TRY", Value: "32.15"}.Invert()
+	require.NoError(t, err)
+	require.Equal(t, "0.03110420", inverted.Value)
+
+	_, err = Rate{From: "USD", To: "EUR", Value: "0"}.Invert()
+	require.True(t, errors.Is(err, ErrInvalidRate))
+}
+
+func TestStaticRateProvider(t *testing.T) {
+	provider, err := LoadStaticRateProvider("testdata/rates.json")
+	require.NoError(t, err)
+
+	rate, err := provider.GetRate(context.Background(), "USD", "EUR")
+	require.NoError(t, err)
+	require.Equal(t, Rate{From: "USD", To: "EUR", Value: "0.92"}, rate)
+
+	_, err = provider.GetRate(context.Background(), "EUR", "TRY")
+	require.True(t, errors.Is(err, ErrRateNotFound))
+
+	_, err = NewStaticRateProvider([]Rate{{From: "USD", To: "EUR", Value: "0"}})
+	require.True(t, errors.Is(err, ErrInvalidRate))
+}
+
+func TestRateConvertMinorUnits(t *testing.T) {
+	// 1.00 USD -> 150 JPY
+	converted, err := Rate{From: "USD", To: "JPY", Value: "150"}.ConvertMinorUnits(100, 2, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(150), converted)
+
+	// 150 JPY -> 1.00 USD
+	converted, err = Rate{From: "JPY", To: "USD", Value: "0.0066667"}.ConvertMinorUnits(150, 0, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), converted)
+
+	// 1.00 USD -> 0.377 BHD
+	converted, err = Rate{From: "USD", To: "BHD", Value: "0.377"}.ConvertMinorUnits(100, 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(377), converted)
+
+	// 0.01 USD is less than half a yen
+	_, err = Rate{From: "USD", To: "JPY", Value: "30"}.ConvertMinorUnits(1, 2, 0)
+	require.True(t, errors.Is(err, ErrAmountTooSmall))
+}
@@ -0,0 +1,60 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+type currencyPair struct {
+	from string
+	to   string
+}
+
+// StaticRateProvider serves a fixed set of rates, e.g. loaded from a JSON file
+// it is useful for local development and tests, rates never change while the server is running
+type StaticRateProvider struct {
+	rates map[currencyPair]Rate
+}
+
+// NewStaticRateProvider creates a new StaticRateProvider with the given rates
+func NewStaticRateProvider(rates []Rate) (*StaticRateProvider, error) {
+	provider := &StaticRateProvider{
+		rates: make(map[currencyPair]Rate, len(rates)),
+	}
+
+	for _, rate := range rates {
+		if err := rate.Validate(); err != nil {
+			return nil, err
+		}
+		provider.rates[currencyPair{rate.From, rate.To}] = rate
+	}
+
+	return provider, nil
+}
+
+// LoadStaticRateProvider reads the rates from a JSON file
+// the file contains a list of rates: [{"from": "USD", "to": "EUR", "value": "0.92"}, ...]
+func LoadStaticRateProvider(path string) (*StaticRateProvider, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rates file: %w", err)
+	}
+
+	var rates []Rate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("cannot parse rates file: %w", err)
+	}
+
+	return NewStaticRateProvider(rates)
+}
+
+// GetRate returns the rate to convert an amount in from currency to currency
+func (provider *StaticRateProvider) GetRate(ctx context.Context, from string, to string) (Rate, error) {
+	rate, ok := provider.rates[currencyPair{from, to}]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s->%s", ErrRateNotFound, from, to)
+	}
+	return rate, nil
+}
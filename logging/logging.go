@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+)
+
+// Formats of the log lines, see util.Config.LogFormat
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// RequestIDField is the name of the request id in the log lines
+const RequestIDField = "request_id"
+
+// contextKey is unexported, so no other package can overwrite or read the values of this one
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// New creates a logger that writes to out with the level and format of the config
+// an empty level means info, and an empty format means json
+func New(config util.Config, out io.Writer) (zerolog.Logger, error) {
+	level := zerolog.InfoLevel
+	if len(config.LogLevel) > 0 {
+		var err error
+		level, err = zerolog.ParseLevel(config.LogLevel)
+		if err != nil {
+			return zerolog.Nop(), fmt.Errorf("invalid log level %q", config.LogLevel)
+		}
+	}
+
+	switch config.LogFormat {
+	case "", FormatJSON:
+	case FormatConsole:
+		// human readable lines for local development, json is easier to ship and query
+		out = zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
+	default:
+		return zerolog.Nop(), fmt.Errorf("invalid log format %q", config.LogFormat)
+	}
+
+	return zerolog.New(out).Level(level).With().Timestamp().Logger(), nil
+}
+
+// WithRequestID returns a copy of ctx that carries the request id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request id carried by ctx, or an empty string if there is none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// ForContext returns logger with the request id of ctx, so the lines of a request can be found together
+func ForContext(ctx context.Context, logger zerolog.Logger) zerolog.Logger {
+	requestID := RequestID(ctx)
+	if len(requestID) == 0 {
+		return logger
+	}
+	return logger.With().Str(RequestIDField, requestID).Logger()
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config util.Config
+		// logged says whether an info line is written, json whether it is written as json
+		logged bool
+		json   bool
+		isErr  bool
+	}{
+		{name: "Default", config: util.Config{}, logged: true, json: true},
+		{name: "JSON", config: util.Config{LogLevel: "info", LogFormat: FormatJSON}, logged: true, json: true},
+		{name: "Console", config: util.Config{LogLevel: "debug", LogFormat: FormatConsole}, logged: true},
+		{name: "HigherLevel", config: util.Config{LogLevel: "warn"}},
+		{name: "InvalidLevel", config: util.Config{LogLevel: "verbose"}, isErr: true},
+		{name: "InvalidFormat", config: util.Config{LogFormat: "xml"}, isErr: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger, err := New(tc.config, &out)
+			if tc.isErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			logger.Info().Str("key", "value").Msg("hello")
+			if !tc.logged {
+				require.Zero(t, out.Len())
+				return
+			}
+			require.Contains(t, out.String(), "hello")
+
+			var line map[string]interface{}
+			err = json.Unmarshal(out.Bytes(), &line)
+			require.Equal(t, tc.json, err == nil)
+			if tc.json {
+				require.Equal(t, "info", line["level"])
+				require.Equal(t, "value", line["key"])
+				require.Contains(t, line, "time")
+			}
+		})
+	}
+}
+
+func TestForContext(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(util.Config{}, &out)
+	require.NoError(t, err)
+
+	// no request id, no field
+	background := ForContext(context.Background(), logger)
+	background.Info().Msg("background")
+	require.NotContains(t, out.String(), RequestIDField)
+	out.Reset()
+
+	ctx := WithRequestID(context.Background(), "abc")
+	require.Equal(t, "abc", RequestID(ctx))
+
+	requestLogger := ForContext(ctx, logger)
+	requestLogger.Info().Msg("request")
+
+	var line map[string]interface{}
+	err = json.Unmarshal(out.Bytes(), &line)
+	require.NoError(t, err)
+	require.Equal(t, "abc", line[RequestIDField])
+}
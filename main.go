@@ -1,34 +1,189 @@
 package main
 
 import (
-	"database/sql"
-	"log"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/keremakillioglu/simplebank/util"
 
 	"github.com/keremakillioglu/simplebank/api"
 	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/fee"
+	"github.com/keremakillioglu/simplebank/fx"
+	"github.com/keremakillioglu/simplebank/logging"
+	"github.com/keremakillioglu/simplebank/metrics"
+	"github.com/keremakillioglu/simplebank/tracing"
+	"github.com/keremakillioglu/simplebank/worker"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
 func main() {
 
+	// the global logger of zerolog is only used until the configured one is created
 	config, err := util.LoadConfig(".")
 	if err != nil {
-		log.Fatal("cannot load config:", err)
+		log.Fatal().Err(err).Msg("cannot load config")
 	}
 
-	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	logger, err := logging.New(config, os.Stderr)
 	if err != nil {
-		log.Fatal("cannot connect to db:", err)
+		log.Fatal().Err(err).Msg("cannot create logger")
 	}
 
-	store := db.NewStore(conn)
-	server := api.NewServer(store)
+	if config.CurrenciesFile != "" {
+		currencies, err := util.LoadCurrencyRegistry(config.CurrenciesFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("cannot load currencies")
+		}
+		util.SetCurrencyRegistry(currencies)
+	}
+
+	// spans of the stdout exporter go to stdout, the logs go to stderr
+	shutdownTracing, err := tracing.Setup(config, os.Stdout, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("cannot set up tracing")
+	}
+
+	// the store charges the fees and the server previews them, no fees are charged without a schedule file
+	feeSchedule, err := fee.NewSchedule(nil, nil)
+	if config.FeeScheduleFile != "" {
+		feeSchedule, err = fee.LoadSchedule(config.FeeScheduleFile)
+	}
+	if err != nil {
+		logger.Fatal().Err(err).Msg("cannot load fee schedule")
+	}
+
+	// the store checks the user limits with the same rates the server converts the transfers with
+	// the exchange_rates table is used without a rates file
+	var rateProvider fx.RateProvider
+	if config.FXRatesFile != "" {
+		rateProvider, err = fx.LoadStaticRateProvider(config.FXRatesFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("cannot load exchange rates")
+		}
+	}
+
+	conn, err := db.OpenDB(config.DBDriver, config.DBSource)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("cannot connect to db")
+	}
+
+	store := db.NewStore(conn, logger, feeSchedule, rateProvider)
+
+	// go run main.go reconcile [-record], checks the ledger instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		code := reconcile(store, logger, os.Args[2:])
+		conn.Close()
+		shutdownTracing(context.Background())
+		os.Exit(code)
+	}
+
+	// gauges of the connection pool, served on /metrics with the other metrics
+	prometheus.MustRegister(metrics.NewDBStatsCollector(conn))
+
+	server, err := api.NewServer(config, store, feeSchedule, rateProvider, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("cannot create server")
+	}
+
+	scheduler, err := worker.NewScheduler(config, store, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("cannot create scheduler")
+	}
+	scheduler.Start()
 
-	err = server.Start(config.ServerAddress)
+	var reconciler *worker.Reconciler
+	if config.ReconcileInterval > 0 {
+		reconciler, err = worker.NewReconciler(config, store, logger)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("cannot create reconciler")
+		}
+		reconciler.Start()
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Start()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err = <-serverErr:
+		if err != nil {
+			logger.Error().Err(err).Msg("cannot start server")
+		}
+	case sig := <-quit:
+		logger.Info().Str("signal", sig.String()).Msg("shutting down")
+
+		// new requests are refused, the in-flight ones get until the deadline to finish
+		ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error().Err(err).Msg("cannot drain requests")
+		}
+		cancel()
+	}
+
+	// let the running scheduled transfer and reconciliation finish, before the db they use is closed
+	scheduler.Stop()
+	if reconciler != nil {
+		reconciler.Stop()
+	}
+
+	if err := conn.Close(); err != nil {
+		logger.Error().Err(err).Msg("cannot close db")
+	}
+
+	// the pending spans are exported before exit
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error().Err(err).Msg("cannot flush spans")
+	}
+	cancel()
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// reconcile prints the reconciliation report of the ledger as JSON
+// the exit code is 0 if the ledger is consistent, 1 if it has discrepancies and 2 if it cannot be checked
+func reconcile(store db.Store, logger zerolog.Logger, args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	record := flags.Bool("record", false, "write the report to the reconciliation_runs table")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	report, err := store.ReconcileTx(ctx)
 	if err != nil {
-		log.Fatal("cannot start server:", err)
+		logger.Error().Err(err).Msg("cannot reconcile ledger")
+		return 2
 	}
 
+	if *record {
+		if _, err := store.RecordReconciliation(ctx, report); err != nil {
+			logger.Error().Err(err).Msg("cannot record reconciliation")
+			return 2
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		logger.Error().Err(err).Msg("cannot print reconciliation")
+		return 2
+	}
+
+	if report.Discrepancies() > 0 {
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBStatsCollector exports the sql.DBStats of the connection pool, they are read on every scrape
+type DBStatsCollector struct {
+	db *sql.DB
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// NewDBStatsCollector creates a collector of the pool of db, register it with prometheus.MustRegister
+func NewDBStatsCollector(db *sql.DB) *DBStatsCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, nil, nil)
+	}
+
+	return &DBStatsCollector{
+		db:                db,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the db, zero means no limit."),
+		open:              desc("open_connections", "Number of established connections, both in use and idle."),
+		inUse:             desc("in_use_connections", "Number of connections in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_count_total", "Number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "Time spent waiting for a connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Number of connections closed because of the max idle connections."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Number of connections closed because of the max connection lifetime."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (collector *DBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.maxOpen
+	ch <- collector.open
+	ch <- collector.inUse
+	ch <- collector.idle
+	ch <- collector.waitCount
+	ch <- collector.waitDuration
+	ch <- collector.maxIdleClosed
+	ch <- collector.maxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector
+func (collector *DBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := collector.db.Stats()
+
+	ch <- prometheus.MustNewConstMetric(collector.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(collector.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(collector.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(collector.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(collector.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(collector.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(collector.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(collector.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
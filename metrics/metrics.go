@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace is the prefix of every metric of the service
+const namespace = "simplebank"
+
+// Reasons of the failed transfers, the label values of TransferFailures
+const (
+	ReasonInsufficientFunds = "insufficient_funds"
+	ReasonCurrencyMismatch  = "currency_mismatch"
+	ReasonNotFound          = "not_found"
+	ReasonAccountInactive   = "account_inactive"
+	ReasonLimitExceeded     = "limit_exceeded"
+	ReasonOther             = "other"
+)
+
+// metrics are registered with the default registry, which is served by promhttp.Handler
+var (
+	// HTTPRequests is labelled with the route pattern rather than the path, so ids dont create new series
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route, method and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// TxDuration includes the retries of the transaction, outcome is commit or rollback
+	TxDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "tx_duration_seconds",
+		Help:      "Latency of the db transactions, including their retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	TxRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "tx_retries_total",
+		Help:      "Number of times a db transaction was run again after a serialization failure or deadlock.",
+	})
+
+	TxRetriesExhausted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "tx_retries_exhausted_total",
+		Help:      "Number of db transactions that still failed after the last retry.",
+	})
+
+	TransfersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_created_total",
+		Help:      "Number of committed transfers by the currency of the sender.",
+	}, []string{"currency"})
+
+	// TransferVolume is in the minor units of the currency, e.g. cents
+	TransferVolume = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_volume_total",
+		Help:      "Amount sent with the committed transfers, in the minor units of the currency of the sender.",
+	}, []string{"currency"})
+
+	TransferFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_failures_total",
+		Help:      "Number of rejected transfer requests by reason.",
+	}, []string{"reason"})
+
+	AccountsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accounts_created_total",
+		Help:      "Number of accounts created by currency.",
+	}, []string{"currency"})
+)
+
+// TransferCreated counts a committed transfer of amount in currency
+func TransferCreated(currency string, amount int64) {
+	TransfersCreated.WithLabelValues(currency).Inc()
+	TransferVolume.WithLabelValues(currency).Add(float64(amount))
+}
+
+// TransferFailed counts a rejected transfer, reason is one of the Reason constants
+func TransferFailed(reason string) {
+	TransferFailures.WithLabelValues(reason).Inc()
+}
+
+// AccountCreated counts a new account in currency
+func AccountCreated(currency string) {
+	AccountsCreated.WithLabelValues(currency).Inc()
+}
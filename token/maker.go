@@ -0,0 +1,13 @@
+package token
+
+import "time"
+
+// Maker is an interface for managing tokens
+// any token implementation (JWT, PASETO, ...) can be plugged into the server as long as it satisfies this interface
+type Maker interface {
+	// CreateToken creates a new token of a type for a specific username and duration
+	CreateToken(username string, tokenType TokenType, duration time.Duration) (string, *Payload, error)
+
+	// VerifyToken checks if the token is a valid token of the type or not, and returns the payload stored inside the token
+	VerifyToken(token string, tokenType TokenType) (*Payload, error)
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters of the spans, see util.Config.TraceExporter
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	serviceName = "simplebank"
+	tracerName  = "github.com/keremakillioglu/simplebank"
+)
+
+// Setup installs the tracer provider of the config and the W3C trace context propagator globally
+// stdout spans are written to out, the returned function flushes the pending spans and stops the exporter
+// errors of the exporter, e.g. an unreachable collector, are logged with logger
+func Setup(config util.Config, out io.Writer, logger zerolog.Logger) (func(context.Context) error, error) {
+	// incoming trace context is propagated even when the spans are not exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(errorHandler{logger: logger})
+
+	if len(config.TraceExporter) == 0 || config.TraceExporter == ExporterNone {
+		// the default provider doesnt record anything
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if config.TraceSampleRatio < 0 || config.TraceSampleRatio > 1 {
+		return nil, fmt.Errorf("trace sample ratio must be between 0 and 1, not %v", config.TraceSampleRatio)
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch config.TraceExporter {
+	case ExporterStdout:
+		var err error
+		exporter, err = stdout.NewExporter(stdout.WithWriter(out), stdout.WithPrettyPrint(), stdout.WithoutMetricExport())
+		if err != nil {
+			return nil, fmt.Errorf("cannot create stdout exporter: %w", err)
+		}
+	case ExporterOTLP:
+		options := []otlpgrpc.Option{otlpgrpc.WithEndpoint(config.OTLPEndpoint)}
+		if config.OTLPInsecure {
+			options = append(options, otlpgrpc.WithInsecure())
+		}
+
+		var err error
+		// the exporter connects in the background, so an unavailable collector doesnt stop the server
+		exporter, err = otlp.NewExporter(context.Background(), otlpgrpc.NewDriver(options...))
+		if err != nil {
+			return nil, fmt.Errorf("cannot create otlp exporter: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q", config.TraceExporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		// a trace sampled by the caller is always sampled, so it is not cut in the middle
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TraceSampleRatio))),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// errorHandler logs the errors of the exporter instead of printing them with the log package
+type errorHandler struct {
+	logger zerolog.Logger
+}
+
+func (h errorHandler) Handle(err error) {
+	if err != nil {
+		h.logger.Error().Err(err).Msg("tracing error")
+	}
+}
+
+// Tracer returns the tracer of the service, from the global provider installed by Setup
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start starts a span as the child of the span of ctx
+// handlers pass their request context, tracingMiddleware puts the span of the request there
+func Start(ctx context.Context, name string, opts ...trace.SpanOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End records the error of the span if there is one, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
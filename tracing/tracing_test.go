@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useTestProvider installs a provider that keeps the spans in memory until the end of the test
+func useTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestSetup(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	testCases := []struct {
+		name   string
+		config util.Config
+		isErr  bool
+	}{
+		{name: "Default", config: util.Config{}},
+		{name: "None", config: util.Config{TraceExporter: ExporterNone}},
+		{name: "Stdout", config: util.Config{TraceExporter: ExporterStdout, TraceSampleRatio: 1}},
+		{name: "OTLP", config: util.Config{TraceExporter: ExporterOTLP, TraceSampleRatio: 0.5, OTLPEndpoint: "localhost:4317", OTLPInsecure: true}},
+		{name: "InvalidExporter", config: util.Config{TraceExporter: "zipkin"}, isErr: true},
+		{name: "InvalidSampleRatio", config: util.Config{TraceExporter: ExporterStdout, TraceSampleRatio: 2}, isErr: true},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			shutdown, err := Setup(tc.config, &out, zerolog.Nop())
+			if tc.isErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			_, span := Start(context.Background(), "test")
+			span.End()
+
+			// shutdown flushes the batch, the collector of the otlp case is not running so it is not waited for long
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			shutdown(ctx)
+
+			if tc.config.TraceExporter == ExporterStdout {
+				require.Contains(t, out.String(), `"Name": "test"`)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	exporter := useTestProvider(t)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("failed"))
+	End(parent, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name)
+	require.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	require.Equal(t, codes.Error, spans[0].StatusCode)
+	require.Equal(t, codes.Unset, spans[1].StatusCode)
+}
@@ -1,15 +1,59 @@
 package util
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 // Config stores all configuration of the application
 // The values are read by viper from config file or environment variable
 type Config struct {
-	DBDriver      string `mapstructure:"DB_DRIVER"`
-	DBSource      string `mapstructure:"DB_SOURCE"`
-	ServerAddress string `mapstructure:"SERVER_ADDRESS"`
+	DBDriver             string        `mapstructure:"DB_DRIVER"`
+	DBSource             string        `mapstructure:"DB_SOURCE"`
+	ServerAddress        string        `mapstructure:"SERVER_ADDRESS"`
+	TokenSymmetricKey    string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	// paseto or jwt, both are signed with the symmetric key
+	TokenFormat string `mapstructure:"TOKEN_FORMAT"`
+	// rates are read from the exchange_rates table when no file is given
+	FXRatesFile     string        `mapstructure:"FX_RATES_FILE"`
+	FXQuoteDuration time.Duration `mapstructure:"FX_QUOTE_DURATION"`
+	// DefaultCurrencies are supported when no file is given
+	CurrenciesFile string `mapstructure:"CURRENCIES_FILE"`
+	// pending holds are released automatically after this duration
+	HoldDuration time.Duration `mapstructure:"HOLD_DURATION"`
+	// due scheduled transfers are looked for every poll interval
+	// a failed one is retried after the retry interval, and skipped after max attempts
+	SchedulePollInterval  time.Duration `mapstructure:"SCHEDULE_POLL_INTERVAL"`
+	ScheduleRetryInterval time.Duration `mapstructure:"SCHEDULE_RETRY_INTERVAL"`
+	ScheduleMaxAttempts   int32         `mapstructure:"SCHEDULE_MAX_ATTEMPTS"`
+	// no fees are charged when no file is given
+	FeeScheduleFile string `mapstructure:"FEE_SCHEDULE_FILE"`
+	// the ledger is reconciled in the background every interval, zero disables it
+	ReconcileInterval time.Duration `mapstructure:"RECONCILE_INTERVAL"`
+	// timeouts of the HTTP server, zero means no timeout
+	// write timeout must be longer than the slowest request, e.g. a long statement
+	HTTPReadHeaderTimeout time.Duration `mapstructure:"HTTP_READ_HEADER_TIMEOUT"`
+	HTTPReadTimeout       time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout      time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout       time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	// in-flight requests are given this long to finish on SIGINT or SIGTERM
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	// readiness fails for this long before the server stops accepting requests, it is part of the shutdown timeout
+	ShutdownDelay time.Duration `mapstructure:"SHUTDOWN_DELAY"`
+	// the readiness probe gives up on the db after this timeout, zero means no timeout
+	ReadinessTimeout time.Duration `mapstructure:"READINESS_TIMEOUT"`
+	// LogLevel is one of trace, debug, info, warn and error, LogFormat is json or console
+	LogLevel  string `mapstructure:"LOG_LEVEL"`
+	LogFormat string `mapstructure:"LOG_FORMAT"`
+	// TraceExporter is none, stdout or otlp, the otlp exporter sends the spans to a collector with grpc
+	// traces started by the callers are always sampled, the ratio applies to the other ones
+	TraceExporter    string  `mapstructure:"TRACE_EXPORTER"`
+	TraceSampleRatio float64 `mapstructure:"TRACE_SAMPLE_RATIO"`
+	OTLPEndpoint     string  `mapstructure:"OTLP_ENDPOINT"`
+	OTLPInsecure     bool    `mapstructure:"OTLP_INSECURE"`
 }
 
 // LoadConfig reads configurations from file or environment variables
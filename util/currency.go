@@ -1,18 +1,145 @@
 package util
 
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
 // Constants for all supported currencies
 const (
 	USD = "USD"
 	EUR = "EUR"
 	TRY = "TRY"
+	JPY = "JPY"
+	BHD = "BHD"
 )
 
-// IsSupportedCurrency returns true if the currency is supported
-func IsSupportedCurrency(currency string) bool {
+// Currency holds the ISO 4217 metadata of a currency
+type Currency struct {
+	// Code is the alphabetic code, e.g. "USD"
+	Code string `json:"code"`
+	// NumericCode is kept as a string, because the leading zeros are significant, e.g. "048" for BHD
+	NumericCode string `json:"numeric_code"`
+	// MinorUnits is the number of decimal places, amounts are stored as integers in this scale
+	// e.g. 2 for USD (cents), 0 for JPY and 3 for BHD (fils)
+	MinorUnits int    `json:"minor_units"`
+	Symbol     string `json:"symbol"`
+}
+
+// DefaultCurrencies are supported when no currencies file is configured
+var DefaultCurrencies = []Currency{
+	{Code: USD, NumericCode: "840", MinorUnits: 2, Symbol: "$"},
+	{Code: EUR, NumericCode: "978", MinorUnits: 2, Symbol: "€"},
+	{Code: TRY, NumericCode: "949", MinorUnits: 2, Symbol: "₺"},
+}
+
+// CurrencyRegistry contains the currencies supported by the bank
+type CurrencyRegistry struct {
+	currencies map[string]Currency
+	// sorted, so RandomCurrency and listings are deterministic
+	codes []string
+}
+
+// NewCurrencyRegistry creates a new registry with the given currencies
+func NewCurrencyRegistry(currencies []Currency) (*CurrencyRegistry, error) {
+	if len(currencies) == 0 {
+		return nil, fmt.Errorf("currency registry must contain at least one currency")
+	}
+
+	registry := &CurrencyRegistry{
+		currencies: make(map[string]Currency, len(currencies)),
+	}
+
+	for _, currency := range currencies {
+		if len(currency.Code) != 3 {
+			return nil, fmt.Errorf("invalid currency code %q: must be 3 letters", currency.Code)
+		}
+		if len(currency.NumericCode) != 3 {
+			return nil, fmt.Errorf("invalid numeric code %q of %s: must be 3 digits", currency.NumericCode, currency.Code)
+		}
+		// more than 18 decimal places doesnt fit into int64 scale anyway
+		if currency.MinorUnits < 0 || currency.MinorUnits > 18 {
+			return nil, fmt.Errorf("invalid minor units %d of %s", currency.MinorUnits, currency.Code)
+		}
+		if _, ok := registry.currencies[currency.Code]; ok {
+			return nil, fmt.Errorf("duplicate currency %s", currency.Code)
+		}
+
+		registry.currencies[currency.Code] = currency
+		registry.codes = append(registry.codes, currency.Code)
+	}
+
+	sort.Strings(registry.codes)
+	return registry, nil
+}
+
+// LoadCurrencyRegistry reads the currencies from a JSON file
+// the file contains a list of currencies: [{"code": "USD", "numeric_code": "840", "minor_units": 2, "symbol": "$"}, ...]
+func LoadCurrencyRegistry(path string) (*CurrencyRegistry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read currencies file: %w", err)
+	}
 
-	switch currency {
-	case USD, EUR, TRY:
-		return true
+	var currencies []Currency
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return nil, fmt.Errorf("cannot parse currencies file: %w", err)
 	}
-	return false
+
+	return NewCurrencyRegistry(currencies)
+}
+
+// Lookup returns the currency with the given code
+func (registry *CurrencyRegistry) Lookup(code string) (Currency, bool) {
+	currency, ok := registry.currencies[code]
+	return currency, ok
+}
+
+// Codes returns the sorted codes of all currencies in the registry
+func (registry *CurrencyRegistry) Codes() []string {
+	codes := make([]string, len(registry.codes))
+	copy(codes, registry.codes)
+	return codes
+}
+
+// registry used by the package level functions, it is replaced at startup with SetCurrencyRegistry
+var (
+	registryMu      sync.RWMutex
+	defaultRegistry = mustNewCurrencyRegistry(DefaultCurrencies)
+)
+
+func mustNewCurrencyRegistry(currencies []Currency) *CurrencyRegistry {
+	registry, err := NewCurrencyRegistry(currencies)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// SetCurrencyRegistry replaces the registry used by IsSupportedCurrency, LookupCurrency and RandomCurrency
+func SetCurrencyRegistry(registry *CurrencyRegistry) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	defaultRegistry = registry
+}
+
+// Currencies returns the registry that is currently in use
+func Currencies() *CurrencyRegistry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return defaultRegistry
+}
+
+// LookupCurrency returns the metadata of a supported currency
+func LookupCurrency(code string) (Currency, bool) {
+	return Currencies().Lookup(code)
+}
+
+// IsSupportedCurrency returns true if the currency is supported
+func IsSupportedCurrency(currency string) bool {
+	_, ok := LookupCurrency(currency)
+	return ok
 }
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyRegistry(t *testing.T) {
+	registry, err := LoadCurrencyRegistry("../currencies.json")
+	require.NoError(t, err)
+	require.Equal(t, []string{BHD, EUR, JPY, TRY, USD}, registry.Codes())
+
+	currency, ok := registry.Lookup(BHD)
+	require.True(t, ok)
+	require.Equal(t, testBHD, currency)
+
+	_, ok = registry.Lookup("XXX")
+	require.False(t, ok)
+
+	_, err = NewCurrencyRegistry([]Currency{testUSD, testUSD})
+	require.Error(t, err)
+
+	_, err = NewCurrencyRegistry([]Currency{{Code: "US", NumericCode: "840"}})
+	require.Error(t, err)
+}
+
+func TestSetCurrencyRegistry(t *testing.T) {
+	previous := Currencies()
+	defer SetCurrencyRegistry(previous)
+
+	require.True(t, IsSupportedCurrency(USD))
+	require.False(t, IsSupportedCurrency(JPY))
+
+	registry, err := NewCurrencyRegistry([]Currency{testJPY})
+	require.NoError(t, err)
+	SetCurrencyRegistry(registry)
+
+	require.True(t, IsSupportedCurrency(JPY))
+	require.False(t, IsSupportedCurrency(USD))
+	require.Equal(t, JPY, RandomCurrency())
+
+	money, err := NewMoney(1234, JPY)
+	require.NoError(t, err)
+	require.Equal(t, "1234", money.Decimal())
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAmount is returned by ParseMoney when the decimal amount cannot be parsed
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Money is an amount in the minor units of its currency, e.g. cents for USD or fils for BHD
+// amounts are stored as integers in the db, Money is used to show and read them as decimals
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// NewMoney creates a Money of a supported currency
+func NewMoney(amount int64, code string) (Money, error) {
+	currency, ok := LookupCurrency(code)
+	if !ok {
+		return Money{}, fmt.Errorf("unsupported currency %s", code)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Decimal formats the amount with the number of decimal places of the currency
+// e.g. 1234 is "12.34" in USD, "1234" in JPY and "1.234" in BHD
+func (money Money) Decimal() string {
+	minorUnits := money.Currency.MinorUnits
+
+	sign := ""
+	abs := uint64(money.Amount)
+	if money.Amount < 0 {
+		sign = "-"
+		// two's complement makes this correct for math.MinInt64 as well
+		abs = uint64(-money.Amount)
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if minorUnits == 0 {
+		return sign + digits
+	}
+
+	// pad with zeros so there is at least one digit before the decimal point
+	if len(digits) <= minorUnits {
+		digits = strings.Repeat("0", minorUnits-len(digits)+1) + digits
+	}
+
+	point := len(digits) - minorUnits
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// String formats the money with its currency symbol, e.g. "$12.34"
+func (money Money) String() string {
+	decimal := money.Decimal()
+	if strings.HasPrefix(decimal, "-") {
+		return "-" + money.Currency.Symbol + decimal[1:]
+	}
+	return money.Currency.Symbol + decimal
+}
+
+// ParseMoney reads a decimal amount like "12.34" in the minor units of the currency
+// more decimal places than the currency has are rejected instead of being rounded
+func ParseMoney(s string, currency Currency) (Money, error) {
+	value := strings.TrimSpace(s)
+
+	negative := false
+	switch {
+	case strings.HasPrefix(value, "-"):
+		negative = true
+		value = value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+
+	whole := value
+	fraction := ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		whole = value[:i]
+		fraction = value[i+1:]
+		if len(fraction) == 0 {
+			return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+	}
+
+	if len(whole) == 0 || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	if len(fraction) > currency.MinorUnits {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimal places for %s", ErrInvalidAmount, s, currency.MinorUnits, currency.Code)
+	}
+
+	// "1.5" in BHD is 1500 fils
+	digits := whole + fraction + strings.Repeat("0", currency.MinorUnits-len(fraction))
+	abs, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || (!negative && abs > math.MaxInt64) || (negative && abs > uint64(math.MaxInt64)+1) {
+		return Money{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, s)
+	}
+
+	// two's complement makes this correct for math.MinInt64 as well
+	amount := int64(abs)
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
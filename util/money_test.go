@@ -0,0 +1,89 @@
+package util
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testUSD = Currency{Code: USD, NumericCode: "840", MinorUnits: 2, Symbol: "$"}
+	testJPY = Currency{Code: JPY, NumericCode: "392", MinorUnits: 0, Symbol: "¥"}
+	testBHD = Currency{Code: BHD, NumericCode: "048", MinorUnits: 3, Symbol: "BD"}
+)
+
+func TestMoneyDecimal(t *testing.T) {
+	testCases := []struct {
+		money    Money
+		expected string
+	}{
+		{Money{Amount: 1234, Currency: testUSD}, "12.34"},
+		{Money{Amount: 5, Currency: testUSD}, "0.05"},
+		{Money{Amount: -5, Currency: testUSD}, "-0.05"},
+		{Money{Amount: 0, Currency: testUSD}, "0.00"},
+		{Money{Amount: 1234, Currency: testJPY}, "1234"},
+		{Money{Amount: -1234, Currency: testJPY}, "-1234"},
+		{Money{Amount: 1234, Currency: testBHD}, "1.234"},
+		{Money{Amount: 1, Currency: testBHD}, "0.001"},
+		{Money{Amount: math.MinInt64, Currency: testUSD}, "-92233720368547758.08"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, tc.money.Decimal())
+	}
+
+	require.Equal(t, "$12.34", Money{Amount: 1234, Currency: testUSD}.String())
+	require.Equal(t, "-¥1234", Money{Amount: -1234, Currency: testJPY}.String())
+}
+
+func TestParseMoney(t *testing.T) {
+	testCases := []struct {
+		s        string
+		currency Currency
+		expected int64
+	}{
+		{"12.34", testUSD, 1234},
+		{"12.3", testUSD, 1230},
+		{"12", testUSD, 1200},
+		{"-0.05", testUSD, -5},
+		{"+1", testUSD, 100},
+		{"1234", testJPY, 1234},
+		{"1.5", testBHD, 1500},
+		{"0.001", testBHD, 1},
+		{"-92233720368547758.08", testUSD, math.MinInt64},
+	}
+
+	for _, tc := range testCases {
+		money, err := ParseMoney(tc.s, tc.currency)
+		require.NoError(t, err, tc.s)
+		require.Equal(t, tc.expected, money.Amount, tc.s)
+		require.Equal(t, tc.currency, money.Currency)
+
+		// formatting and parsing are reversible
+		parsed, err := ParseMoney(money.Decimal(), tc.currency)
+		require.NoError(t, err)
+		require.Equal(t, money, parsed)
+	}
+
+	invalid := []struct {
+		s        string
+		currency Currency
+	}{
+		{"1.5", testJPY},   // yen has no minor unit
+		{"1.234", testUSD}, // cents have 2 decimals
+		{"1.2345", testBHD},
+		{"", testUSD},
+		{"abc", testUSD},
+		{"1.", testUSD},
+		{".5", testUSD},
+		{"1,000.00", testUSD},
+		{"92233720368547758.08", testUSD},
+	}
+
+	for _, tc := range invalid {
+		_, err := ParseMoney(tc.s, tc.currency)
+		require.True(t, errors.Is(err, ErrInvalidAmount), tc.s)
+	}
+}
@@ -46,9 +46,9 @@ func RandomMoney() int64 {
 	return RandomInt(0, 1000)
 }
 
-//RandomCurrency generates a random currency from a provided list
+//RandomCurrency generates a random currency from the supported currencies
 func RandomCurrency() string {
-	currencies := []string{EUR, USD, TRY}
+	currencies := Currencies().Codes()
 	n := len(currencies)
 	return currencies[rand.Intn(n)]
 }
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"errors"
+	"time"
+)
+
+// Frequencies of a recurrence, like FREQ of an RRULE
+const (
+	FrequencyOnce    = "once"
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+// ErrInvalidRecurrence is returned when a recurrence cannot produce any occurrence
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+// Recurrence describes the times of a repeated event, with a subset of the RRULE fields
+type Recurrence struct {
+	Frequency string
+	// Interval is the number of frequency units between two occurrences, e.g. 2 for every other week
+	Interval int
+	// Start is the first occurrence
+	Start time.Time
+	// Until is the last possible time of an occurrence, zero means no end
+	Until time.Time
+	// Count is the maximum number of occurrences, zero means no limit
+	Count int
+}
+
+// Validate checks if the recurrence has at least one occurrence
+func (r Recurrence) Validate() error {
+	switch r.Frequency {
+	case FrequencyOnce, FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+	default:
+		return ErrInvalidRecurrence
+	}
+
+	if r.Interval < 1 || r.Count < 0 || r.Start.IsZero() {
+		return ErrInvalidRecurrence
+	}
+	if !r.Until.IsZero() && r.Until.Before(r.Start) {
+		return ErrInvalidRecurrence
+	}
+	return nil
+}
+
+// Occurrence returns the nth occurrence, starting from 0, without checking Until and Count
+// each occurrence is computed from Start, so a monthly recurrence on the 31st stays on the last day of shorter months
+// instead of drifting to the 28th
+func (r Recurrence) Occurrence(n int) time.Time {
+	steps := n * r.Interval
+	switch r.Frequency {
+	case FrequencyDaily:
+		return r.Start.AddDate(0, 0, steps)
+	case FrequencyWeekly:
+		return r.Start.AddDate(0, 0, 7*steps)
+	case FrequencyMonthly:
+		return addMonths(r.Start, steps)
+	default:
+		return r.Start
+	}
+}
+
+// Next returns the nth occurrence, and false if the recurrence has ended before it
+func (r Recurrence) Next(n int) (time.Time, bool) {
+	if r.Frequency == FrequencyOnce && n > 0 {
+		return time.Time{}, false
+	}
+	if r.Count > 0 && n >= r.Count {
+		return time.Time{}, false
+	}
+
+	next := r.Occurrence(n)
+	if !r.Until.IsZero() && next.After(r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// addMonths adds months to t, the day is clamped to the last day of the resulting month
+// time.AddDate would normalize Jan 31 + 1 month to Mar 3
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+
+	// day 0 of the next month is the last day of this month
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecurrenceOccurrence(t *testing.T) {
+	start := time.Date(2021, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		recurrence Recurrence
+		n          int
+		expected   time.Time
+	}{
+		{"DAILY", Recurrence{Frequency: FrequencyDaily, Interval: 1, Start: start}, 3, time.Date(2021, time.February, 3, 9, 0, 0, 0, time.UTC)},
+		{"EVERYOTHERWEEK", Recurrence{Frequency: FrequencyWeekly, Interval: 2, Start: start}, 1, time.Date(2021, time.February, 14, 9, 0, 0, 0, time.UTC)},
+		{"MONTHLYCLAMPED", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Start: start}, 1, time.Date(2021, time.February, 28, 9, 0, 0, 0, time.UTC)},
+		{"MONTHLYNODRIFT", Recurrence{Frequency: FrequencyMonthly, Interval: 1, Start: start}, 2, time.Date(2021, time.March, 31, 9, 0, 0, 0, time.UTC)},
+		{"MONTHLYLEAPYEAR", Recurrence{Frequency: FrequencyMonthly, Interval: 12, Start: time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)}, 1, time.Date(2021, time.February, 28, 0, 0, 0, 0, time.UTC)},
+		{"ONCE", Recurrence{Frequency: FrequencyOnce, Interval: 1, Start: start}, 0, start},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.recurrence.Validate())
+			require.Equal(t, tc.expected, tc.recurrence.Occurrence(tc.n))
+		})
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	start := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	once := Recurrence{Frequency: FrequencyOnce, Interval: 1, Start: start}
+	_, ok := once.Next(0)
+	require.True(t, ok)
+	_, ok = once.Next(1)
+	require.False(t, ok)
+
+	// COUNT=2
+	counted := Recurrence{Frequency: FrequencyDaily, Interval: 1, Start: start, Count: 2}
+	_, ok = counted.Next(1)
+	require.True(t, ok)
+	_, ok = counted.Next(2)
+	require.False(t, ok)
+
+	// UNTIL is inclusive
+	until := Recurrence{Frequency: FrequencyWeekly, Interval: 1, Start: start, Until: start.AddDate(0, 0, 7)}
+	next, ok := until.Next(1)
+	require.True(t, ok)
+	require.Equal(t, until.Until, next)
+	_, ok = until.Next(2)
+	require.False(t, ok)
+}
+
+func TestRecurrenceValidate(t *testing.T) {
+	start := time.Now()
+
+	require.Error(t, Recurrence{Frequency: "yearly", Interval: 1, Start: start}.Validate())
+	require.Error(t, Recurrence{Frequency: FrequencyDaily, Start: start}.Validate())
+	require.Error(t, Recurrence{Frequency: FrequencyDaily, Interval: 1}.Validate())
+	require.Error(t, Recurrence{Frequency: FrequencyDaily, Interval: 1, Start: start, Until: start.Add(-time.Hour)}.Validate())
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+)
+
+// Reconciler reconciles the ledger periodically in the background, and records every run
+// it only reads the ledger, so running one in each instance of the server is safe but wasteful
+type Reconciler struct {
+	store    db.Store
+	interval time.Duration
+	logger   zerolog.Logger
+	// closed by Stop, and by the run loop when it returns
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReconciler creates a new reconciler, it doesnt run until Start is called
+func NewReconciler(config util.Config, store db.Store, logger zerolog.Logger) (*Reconciler, error) {
+	if config.ReconcileInterval <= 0 {
+		return nil, errors.New("reconcile interval must be positive")
+	}
+
+	return &Reconciler{
+		store:    store,
+		interval: config.ReconcileInterval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start runs the reconciler in a new goroutine, the first run is after one interval
+func (reconciler *Reconciler) Start() {
+	go reconciler.run()
+}
+
+// Stop waits for the running reconciliation to finish, and stops the reconciler
+// it must be called once, after Start
+func (reconciler *Reconciler) Stop() {
+	close(reconciler.stop)
+	<-reconciler.done
+}
+
+func (reconciler *Reconciler) run() {
+	defer close(reconciler.done)
+
+	ticker := time.NewTicker(reconciler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-reconciler.stop:
+			return
+		case <-ticker.C:
+		}
+
+		reconciler.reconcile()
+	}
+}
+
+// reconcile runs and records a single reconciliation, problems are logged so they can be alerted on
+func (reconciler *Reconciler) reconcile() {
+	ctx := context.Background()
+
+	report, err := reconciler.store.ReconcileTx(ctx)
+	if err != nil {
+		reconciler.logger.Error().Err(err).Msg("cannot reconcile ledger")
+		return
+	}
+
+	run, err := reconciler.store.RecordReconciliation(ctx, report)
+	if err != nil {
+		reconciler.logger.Error().Err(err).Msg("cannot record reconciliation")
+	}
+
+	if report.Discrepancies() > 0 {
+		reconciler.logger.Warn().
+			Int64("run_id", run.ID).
+			Int64("discrepancies", report.Discrepancies()).
+			Int("balance_drifts", len(report.BalanceDrifts)).
+			Int("orphan_entries", len(report.OrphanEntries)).
+			Int("unbalanced_transfers", len(report.UnbalancedTransfers)).
+			Int("unbalanced_journals", len(report.UnbalancedJournals)).
+			Msg("ledger reconciliation found discrepancies")
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcilerRecordsRuns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	recorded := make(chan struct{})
+
+	report := db.Reconciliation{
+		AccountsChecked: 2,
+		BalanceDrifts:   []db.ListBalanceDriftsRow{{AccountID: 1, Balance: 100, Drift: 100}},
+	}
+
+	gomock.InOrder(
+		store.EXPECT().ReconcileTx(gomock.Any()).Times(1).Return(report, nil),
+		store.EXPECT().
+			RecordReconciliation(gomock.Any(), gomock.Eq(report)).
+			Times(1).
+			DoAndReturn(func(_ interface{}, _ db.Reconciliation) (db.ReconciliationRun, error) {
+				close(recorded)
+				return db.ReconciliationRun{ID: 1, Discrepancies: 1}, nil
+			}),
+		// a failed run is logged, and the next one is tried after an interval
+		store.EXPECT().ReconcileTx(gomock.Any()).AnyTimes().Return(db.Reconciliation{}, errors.New("db is down")),
+	)
+
+	reconciler, err := NewReconciler(util.Config{ReconcileInterval: 10 * time.Millisecond}, store, zerolog.Nop())
+	require.NoError(t, err)
+	reconciler.Start()
+
+	select {
+	case <-recorded:
+	case <-time.After(time.Second):
+		t.Fatal("reconciler didnt run")
+	}
+
+	reconciler.Stop()
+}
+
+func TestNewReconcilerInvalidConfig(t *testing.T) {
+	_, err := NewReconciler(util.Config{}, nil, zerolog.Nop())
+	require.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+)
+
+// Scheduler runs the due scheduled transfers in the background
+// many schedulers can run side by side, e.g. one in each instance of the server
+type Scheduler struct {
+	store         db.Store
+	pollInterval  time.Duration
+	retryInterval time.Duration
+	maxAttempts   int32
+	logger        zerolog.Logger
+	// closed by Stop, and by the run loop when it returns
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduler creates a new scheduler, it doesnt run until Start is called
+func NewScheduler(config util.Config, store db.Store, logger zerolog.Logger) (*Scheduler, error) {
+	if config.SchedulePollInterval <= 0 {
+		return nil, errors.New("schedule poll interval must be positive")
+	}
+	if config.ScheduleMaxAttempts < 1 {
+		return nil, errors.New("schedule max attempts must be at least 1")
+	}
+
+	return &Scheduler{
+		store:         store,
+		pollInterval:  config.SchedulePollInterval,
+		retryInterval: config.ScheduleRetryInterval,
+		maxAttempts:   config.ScheduleMaxAttempts,
+		logger:        logger,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// Start runs the scheduler in a new goroutine
+func (scheduler *Scheduler) Start() {
+	go scheduler.run()
+}
+
+// Stop waits for the running transfer to finish, and stops the scheduler
+// it must be called once, after Start
+func (scheduler *Scheduler) Stop() {
+	close(scheduler.stop)
+	<-scheduler.done
+}
+
+func (scheduler *Scheduler) run() {
+	defer close(scheduler.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-scheduler.stop:
+			return
+		case <-timer.C:
+		}
+
+		scheduler.runDue()
+		timer.Reset(scheduler.pollInterval)
+	}
+}
+
+// runDue runs the due schedules one by one until none is left, or the scheduler is stopped
+func (scheduler *Scheduler) runDue() {
+	for {
+		select {
+		case <-scheduler.stop:
+			return
+		default:
+		}
+
+		// the tx is not bound to the stop signal, a running transfer is finished rather than rolled back
+		result, err := scheduler.store.RunScheduledTransferTx(context.Background(), db.RunScheduledTransferTxParams{
+			Now:           time.Now(),
+			RetryInterval: scheduler.retryInterval,
+			MaxAttempts:   scheduler.maxAttempts,
+		})
+		if err == sql.ErrNoRows {
+			return
+		}
+		if err != nil {
+			// wait for the next poll instead of trying again right away
+			scheduler.logger.Error().Err(err).Msg("cannot run scheduled transfer")
+			return
+		}
+
+		if len(result.Run.Error) > 0 {
+			scheduler.logger.Warn().
+				Int64("schedule_id", result.Schedule.ID).
+				Int32("attempt", result.Run.Attempt).
+				Str("error", result.Run.Error).
+				Msg("scheduled transfer failed")
+		}
+	}
+}
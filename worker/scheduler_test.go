@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/keremakillioglu/simplebank/db/mock"
+	db "github.com/keremakillioglu/simplebank/db/sqlc"
+	"github.com/keremakillioglu/simplebank/util"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScheduler(t *testing.T, store db.Store) *Scheduler {
+	config := util.Config{
+		SchedulePollInterval:  time.Hour,
+		ScheduleRetryInterval: time.Minute,
+		ScheduleMaxAttempts:   3,
+	}
+
+	scheduler, err := NewScheduler(config, store, zerolog.Nop())
+	require.NoError(t, err)
+	return scheduler
+}
+
+func TestSchedulerRunsDueTransfers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	ran := make(chan struct{})
+
+	// due schedules are run one by one until none is left
+	gomock.InOrder(
+		store.EXPECT().
+			RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+			Times(2).
+			DoAndReturn(func(_ interface{}, arg db.RunScheduledTransferTxParams) (db.RunScheduledTransferTxResult, error) {
+				require.WithinDuration(t, time.Now(), arg.Now, time.Second)
+				require.Equal(t, time.Minute, arg.RetryInterval)
+				require.Equal(t, int32(3), arg.MaxAttempts)
+				return db.RunScheduledTransferTxResult{}, nil
+			}),
+		store.EXPECT().
+			RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ interface{}, _ db.RunScheduledTransferTxParams) (db.RunScheduledTransferTxResult, error) {
+				close(ran)
+				return db.RunScheduledTransferTxResult{}, sql.ErrNoRows
+			}),
+	)
+
+	scheduler := newTestScheduler(t, store)
+	scheduler.Start()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler didnt run")
+	}
+
+	// next poll is an hour away, so nothing else is run before the stop
+	scheduler.Stop()
+}
+
+func TestSchedulerStopWaitsForRunningTransfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	started := make(chan struct{})
+	finished := false
+
+	store.EXPECT().
+		RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ interface{}, _ db.RunScheduledTransferTxParams) (db.RunScheduledTransferTxResult, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			finished = true
+			return db.RunScheduledTransferTxResult{}, nil
+		})
+
+	scheduler := newTestScheduler(t, store)
+	scheduler.Start()
+	<-started
+
+	// the running transfer is finished, but no other one is started
+	scheduler.Stop()
+	require.True(t, finished)
+}
+
+func TestNewSchedulerInvalidConfig(t *testing.T) {
+	_, err := NewScheduler(util.Config{ScheduleMaxAttempts: 1}, nil, zerolog.Nop())
+	require.Error(t, err)
+
+	_, err = NewScheduler(util.Config{SchedulePollInterval: time.Second}, nil, zerolog.Nop())
+	require.Error(t, err)
+}